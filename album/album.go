@@ -0,0 +1,250 @@
+// Package album finds the cover art of each album in a music library,
+// where covers are stored next to the audio files as cover.jpg,
+// folder.jpg, front.png, AlbumArt_{...}_Large.jpg and so on.
+package album
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sspencer/colorart/loader"
+)
+
+// DefaultNames are the cover name patterns used when Rules.Names is empty,
+// from most to least preferred.
+var DefaultNames = []string{
+	"cover",
+	"folder",
+	"front",
+	"albumart*large",
+	"albumart*",
+	"album",
+	"*cover*",
+	"*front*",
+}
+
+// DefaultExtensions are the image extensions used when Rules.Extensions is
+// empty.
+var DefaultExtensions = []string{".jpg", ".jpeg", ".png", ".gif"}
+
+// Rules decide which file in a folder is the album cover.
+type Rules struct {
+	// Names are path.Match patterns, matched case insensitively against
+	// file names without their extension, from most to least preferred.
+	Names []string
+
+	// Extensions are the image file extensions considered.
+	Extensions []string
+
+	// MinSize skips images narrower or shorter than MinSize pixels.
+	MinSize int
+
+	// PreferLarger picks the image with the most pixels among those
+	// matching the same name pattern, instead of the first by name.
+	PreferLarger bool
+
+	// Embedded falls back to the cover embedded in the first audio file
+	// of a folder without a cover image.
+	Embedded bool
+}
+
+// Album is a folder of audio files, or of cover art.
+type Album struct {
+	Dir string
+
+	// Cover is the cover image file, or the audio file whose embedded
+	// cover is used.  It is empty if no cover was found.
+	Cover string
+
+	// Err is why the folder couldn't be read, which leaves Cover empty.
+	Err error
+}
+
+// Scan walks root and returns every folder holding audio files or a cover
+// image, in lexical order.  Folders that can't be read are returned with
+// their Err, and the rest of root is still scanned.  It fails at once if a
+// name pattern is malformed or root can't be read.
+func Scan(root string, rules *Rules) ([]Album, error) {
+	if err := checkNames(rules); err != nil {
+		return nil, err
+	}
+
+	var albums []Album
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			albums = append(albums, Album{Dir: p, Err: err})
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if p != root && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		names, err := readNames(p)
+		if err != nil {
+			if p == root {
+				return err
+			}
+			albums = append(albums, Album{Dir: p, Err: err})
+			return filepath.SkipDir
+		}
+
+		hasAudio := false
+		for _, n := range names {
+			if loader.IsAudio(n) {
+				hasAudio = true
+				break
+			}
+		}
+
+		cover := findCover(p, names, rules)
+		if hasAudio || cover != "" {
+			albums = append(albums, Album{Dir: p, Cover: cover})
+		}
+		return nil
+	})
+
+	return albums, err
+}
+
+// FindCover returns the cover of the album in dir, or "" if there is none.
+func FindCover(dir string, rules *Rules) (string, error) {
+	if err := checkNames(rules); err != nil {
+		return "", err
+	}
+
+	names, err := readNames(dir)
+	if err != nil {
+		return "", err
+	}
+	return findCover(dir, names, rules), nil
+}
+
+// checkNames returns an error wrapping path.ErrBadPattern for the first
+// malformed pattern of rules.Names, which would otherwise never match.
+func checkNames(rules *Rules) error {
+	if rules == nil {
+		return nil
+	}
+	for _, pattern := range rules.Names {
+		if _, err := path.Match(strings.ToLower(pattern), ""); err != nil {
+			return fmt.Errorf("album: cover name %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// readNames returns the sorted names of the regular files in dir.
+func readNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func findCover(dir string, names []string, rules *Rules) string {
+	var r Rules
+	if rules != nil {
+		r = *rules
+	}
+	if len(r.Names) == 0 {
+		r.Names = DefaultNames
+	}
+	if len(r.Extensions) == 0 {
+		r.Extensions = DefaultExtensions
+	}
+
+	for _, pattern := range r.Names {
+		best, bestArea := "", -1
+		for _, n := range names {
+			if !hasExtension(n, r.Extensions) {
+				continue
+			}
+
+			base := strings.ToLower(strings.TrimSuffix(n, filepath.Ext(n)))
+			if ok, _ := path.Match(strings.ToLower(pattern), base); !ok {
+				continue
+			}
+
+			p := filepath.Join(dir, n)
+			area := 0
+			if r.MinSize > 0 || r.PreferLarger {
+				w, h, ok := imageSize(p)
+				if !ok || w < r.MinSize || h < r.MinSize {
+					continue
+				}
+				area = w * h
+			}
+
+			if !r.PreferLarger {
+				return p
+			}
+			if area > bestArea {
+				best, bestArea = p, area
+			}
+		}
+
+		if best != "" {
+			return best
+		}
+	}
+
+	if r.Embedded {
+		for _, n := range names {
+			if loader.IsAudio(n) {
+				return filepath.Join(dir, n)
+			}
+		}
+	}
+
+	return ""
+}
+
+func hasExtension(name string, extensions []string) bool {
+	ext := filepath.Ext(name)
+	for _, e := range extensions {
+		if strings.EqualFold(ext, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// imageSize reads the dimensions of an image without decoding it.
+func imageSize(filename string) (w, h int, ok bool) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	defer file.Close()
+
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, 0, false
+	}
+	return cfg.Width, cfg.Height, true
+}
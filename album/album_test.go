@@ -0,0 +1,132 @@
+package album
+
+import (
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+)
+
+func writePNG(t *testing.T, filename string, size int) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, image.NewGray(image.Rect(0, 0, size, size))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func touch(t *testing.T, filename string) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filename, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	root := t.TempDir()
+
+	writePNG(t, filepath.Join(root, "a", "back.png"), 10)
+	writePNG(t, filepath.Join(root, "a", "Folder.png"), 10)
+	writePNG(t, filepath.Join(root, "a", "cover.png"), 10)
+	touch(t, filepath.Join(root, "a", "01.mp3"))
+
+	writePNG(t, filepath.Join(root, "b", "AlbumArtSmall.png"), 4)
+	writePNG(t, filepath.Join(root, "b", "AlbumArt_{1234}_Large.png"), 20)
+	touch(t, filepath.Join(root, "b", "01.flac"))
+
+	touch(t, filepath.Join(root, "c", "01.m4a"))
+	touch(t, filepath.Join(root, "notes", "readme.txt"))
+
+	albums, err := Scan(root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		filepath.Join(root, "a"): filepath.Join(root, "a", "cover.png"),
+		filepath.Join(root, "b"): filepath.Join(root, "b", "AlbumArt_{1234}_Large.png"),
+		filepath.Join(root, "c"): "",
+	}
+
+	if len(albums) != len(want) {
+		t.Fatalf("got %d albums, want %d: %v", len(albums), len(want), albums)
+	}
+	for _, a := range albums {
+		if cover, ok := want[a.Dir]; !ok || a.Cover != cover {
+			t.Errorf("%s: cover should be %q, not %q", a.Dir, cover, a.Cover)
+		}
+	}
+
+	cover, err := FindCover(filepath.Join(root, "c"), &Rules{Embedded: true})
+	if err != nil || cover != filepath.Join(root, "c", "01.m4a") {
+		t.Errorf("embedded cover should fall back to the audio file, not %q (%v)", cover, err)
+	}
+}
+
+func TestSizeRules(t *testing.T) {
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "cover.png"), 8)
+	writePNG(t, filepath.Join(dir, "front-small.png"), 16)
+	writePNG(t, filepath.Join(dir, "front.png"), 32)
+
+	rules := &Rules{Names: []string{"cover", "front*"}, MinSize: 10}
+	if cover, _ := FindCover(dir, rules); filepath.Base(cover) != "front-small.png" {
+		t.Errorf("small cover should be skipped for the first large enough match, not %q", cover)
+	}
+
+	rules.PreferLarger = true
+	if cover, _ := FindCover(dir, rules); filepath.Base(cover) != "front.png" {
+		t.Errorf("largest matching cover should be picked, not %q", cover)
+	}
+}
+
+func TestBadPattern(t *testing.T) {
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "cover.png"), 8)
+
+	rules := &Rules{Names: []string{"cover", "[front"}}
+	if _, err := FindCover(dir, rules); !errors.Is(err, path.ErrBadPattern) {
+		t.Errorf("FindCover should fail with path.ErrBadPattern, not %v", err)
+	}
+	if _, err := Scan(dir, rules); !errors.Is(err, path.ErrBadPattern) {
+		t.Errorf("Scan should fail with path.ErrBadPattern, not %v", err)
+	}
+}
+
+func TestScanContinues(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can read every folder")
+	}
+
+	root := t.TempDir()
+	writePNG(t, filepath.Join(root, "a", "cover.png"), 8)
+	writePNG(t, filepath.Join(root, "b", "cover.png"), 8)
+	writePNG(t, filepath.Join(root, "c", "cover.png"), 8)
+	locked := filepath.Join(root, "b")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0755)
+
+	albums, err := Scan(root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(albums) != 3 || albums[0].Cover == "" || albums[2].Cover == "" {
+		t.Fatalf("the other albums should still be found: %v", albums)
+	}
+	if albums[1].Dir != locked || albums[1].Err == nil || albums[1].Cover != "" {
+		t.Errorf("%s should be returned with its error, not %+v", locked, albums[1])
+	}
+}
@@ -0,0 +1,230 @@
+package anim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/sspencer/colorart"
+	"github.com/sspencer/colorart/loader"
+)
+
+var (
+	red  = color.RGBA{255, 0, 0, 255}
+	blue = color.RGBA{0, 0, 255, 255}
+)
+
+func TestDecodeGIF(t *testing.T) {
+	pal := color.Palette{red, blue, color.RGBA{}}
+
+	full := image.NewPaletted(image.Rect(0, 0, 8, 8), pal)
+	part := image.NewPaletted(image.Rect(2, 2, 4, 4), pal)
+	for i := range part.Pix {
+		part.Pix[i] = 1
+	}
+
+	var buf bytes.Buffer
+	err := gif.EncodeAll(&buf, &gif.GIF{
+		Image:    []*image.Paletted{full, part},
+		Delay:    []int{10, 50},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalNone},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames, err := DecodeGIF(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(frames))
+	}
+
+	second, ok := frames[1].Image.(*image.Paletted)
+	if !ok {
+		t.Fatalf("frames sharing a palette should be paletted, not %T", frames[1].Image)
+	}
+	if second.Bounds() != image.Rect(0, 0, 8, 8) {
+		t.Errorf("frame should cover the whole image, not %v", second.Bounds())
+	}
+	if second.At(0, 0) != red || second.At(3, 3) != blue {
+		t.Error("second frame should be composited over the first")
+	}
+	if frames[1].Delay != 500*time.Millisecond {
+		t.Errorf("delay should be 500ms, not %s", frames[1].Delay)
+	}
+}
+
+func TestDecodeAPNG(t *testing.T) {
+	first := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := 0; i < len(first.Pix); i += 4 {
+		copy(first.Pix[i:], []uint8{255, 0, 0, 255})
+	}
+	second := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for i := 0; i < len(second.Pix); i += 4 {
+		copy(second.Pix[i:], []uint8{0, 0, 255, 255})
+	}
+
+	firstChunks := encodedChunks(t, first)
+	secondChunks := encodedChunks(t, second)
+
+	var buf bytes.Buffer
+	buf.WriteString(pngSignature)
+	writeChunk(&buf, "IHDR", firstChunks["IHDR"])
+	writeChunk(&buf, "acTL", be32(2, 0))
+	writeChunk(&buf, "fcTL", frameControl(0, 8, 8, 0, 0, 1, 10))
+	writeChunk(&buf, "IDAT", firstChunks["IDAT"])
+	writeChunk(&buf, "fcTL", frameControl(1, 2, 2, 3, 3, 1, 2))
+	writeChunk(&buf, "fdAT", append(be32(2), secondChunks["IDAT"]...))
+	writeChunk(&buf, "IEND", nil)
+
+	frames, err := DecodeAPNG(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(frames))
+	}
+
+	img := frames[1].Image
+	if img.Bounds() != image.Rect(0, 0, 8, 8) {
+		t.Errorf("frame should cover the whole image, not %v", img.Bounds())
+	}
+	if img.At(0, 0) != red || img.At(3, 3) != blue {
+		t.Error("second frame should be composited over the first")
+	}
+	if frames[0].Delay != 100*time.Millisecond || frames[1].Delay != 500*time.Millisecond {
+		t.Errorf("delays should be 100ms and 500ms, not %s and %s", frames[0].Delay, frames[1].Delay)
+	}
+}
+
+func TestDecodeStillPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+
+	frames, err := DecodeAPNG(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 1 {
+		t.Errorf("still PNG should have 1 frame, not %d", len(frames))
+	}
+}
+
+func encodedChunks(t *testing.T, img image.Image) map[string][]byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	chunks, err := readChunks(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := make(map[string][]byte)
+	for _, c := range chunks {
+		m[c.typ] = append(m[c.typ], c.data...)
+	}
+	return m
+}
+
+func be32(v ...uint32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, n := range v {
+		binary.BigEndian.PutUint32(b[4*i:], n)
+	}
+	return b
+}
+
+func frameControl(seq, w, h, x, y uint32, num, den uint16) []byte {
+	b := be32(seq, w, h, x, y)
+	b = binary.BigEndian.AppendUint16(b, num)
+	b = binary.BigEndian.AppendUint16(b, den)
+	return append(b, apngDisposeNone, apngBlendOver)
+}
+
+func TestFrameBudget(t *testing.T) {
+	// many tiny frames on a large canvas; each is composited into a copy
+	// of the whole canvas
+	const frames = 200
+	pal := color.Palette{red, blue}
+	g := &gif.GIF{Config: image.Config{ColorModel: pal, Width: 100, Height: 100}}
+	for i := 0; i < frames; i++ {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, 1, 1), pal))
+		g.Delay = append(g.Delay, 1)
+	}
+	var gifData bytes.Buffer
+	if err := gif.EncodeAll(&gifData, g); err != nil {
+		t.Fatal(err)
+	}
+
+	ihdr := encodedChunks(t, image.NewRGBA(image.Rect(0, 0, 100, 100)))["IHDR"]
+	tiny := encodedChunks(t, image.NewRGBA(image.Rect(0, 0, 1, 1)))["IDAT"]
+	var apngData bytes.Buffer
+	apngData.WriteString(pngSignature)
+	writeChunk(&apngData, "IHDR", ihdr)
+	writeChunk(&apngData, "acTL", be32(frames, 0))
+	for i := uint32(0); i < frames; i++ {
+		writeChunk(&apngData, "fcTL", frameControl(2*i, 1, 1, 0, 0, 1, 100))
+		writeChunk(&apngData, "fdAT", append(be32(2*i+1), tiny...))
+	}
+	writeChunk(&apngData, "IEND", nil)
+
+	for _, tt := range []struct {
+		name   string
+		decode func(r *bytes.Reader, maxPixels int64) ([]colorart.Frame, error)
+		data   []byte
+	}{
+		{"gif", func(r *bytes.Reader, max int64) ([]colorart.Frame, error) { return DecodeGIFLimit(r, max) }, gifData.Bytes()},
+		{"apng", func(r *bytes.Reader, max int64) ([]colorart.Frame, error) { return DecodeAPNGLimit(r, max) }, apngData.Bytes()},
+	} {
+		// the canvas fits, but not a copy of it for every frame
+		if _, err := tt.decode(bytes.NewReader(tt.data), 100*100*frames-1); !errors.Is(err, loader.ErrTooLarge) {
+			t.Errorf("%s: %d frames should be too large, got %v", tt.name, frames, err)
+		}
+
+		got, err := tt.decode(bytes.NewReader(tt.data), 100*100*frames)
+		if err != nil || len(got) != frames {
+			t.Errorf("%s: %d frames should fit their budget: %d, %v", tt.name, frames, len(got), err)
+		}
+	}
+}
+
+// TestGIFBudgetBeforeDecoding checks that a GIF over its budget fails
+// before any frame is decompressed: every frame here has a bad LZW code
+// size, which gif.DecodeAll rejects as soon as it reaches it.
+func TestGIFBudgetBeforeDecoding(t *testing.T) {
+	const frames = 50
+	var buf bytes.Buffer
+	buf.WriteString("GIF89a")
+	buf.Write([]byte{100, 0, 100, 0, 0, 0, 0}) // 100x100, no global palette
+	for i := 0; i < frames; i++ {
+		buf.Write([]byte{0x21, 0xf9, 4, 0, 1, 0, 0, 0})           // graphic control
+		buf.Write([]byte{0x2c, 0, 0, 0, 0, 100, 0, 100, 0, 0x80}) // full frame, 2 colors
+		buf.Write([]byte{255, 0, 0, 0, 0, 255})
+		buf.Write([]byte{12, 1, 0, 0}) // code size 12, one data byte
+	}
+	buf.WriteByte(0x3b)
+
+	_, err := DecodeGIFLimit(bytes.NewReader(buf.Bytes()), 100*100*frames-1)
+	if !errors.Is(err, loader.ErrTooLarge) {
+		t.Errorf("%d frames should be too large before decoding, got %v", frames, err)
+	}
+
+	_, err = DecodeGIFLimit(bytes.NewReader(buf.Bytes()), 100*100*frames)
+	if err == nil || errors.Is(err, loader.ErrTooLarge) {
+		t.Errorf("%d frames should fit their budget and fail to decode, got %v", frames, err)
+	}
+}
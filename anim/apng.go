@@ -0,0 +1,254 @@
+package anim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"time"
+
+	"github.com/sspencer/colorart"
+	"github.com/sspencer/colorart/loader"
+)
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// APNG frame disposal and blend operations.
+const (
+	apngDisposeNone       = 0
+	apngDisposeBackground = 1
+	apngDisposePrevious   = 2
+
+	apngBlendSource = 0
+	apngBlendOver   = 1
+)
+
+// ErrFormat is returned for malformed animated PNG data.
+var ErrFormat = errors.New("anim: invalid APNG data")
+
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+// apngFrame is a frame control (fcTL) chunk and the image data following it.
+type apngFrame struct {
+	width, height int
+	x, y          int
+	delay         time.Duration
+	dispose       byte
+	blend         byte
+	data          []byte
+}
+
+// DecodeAPNG decodes every frame of an animated PNG into *image.RGBA
+// frames within loader.DefaultMaxPixels.  A PNG without animation decodes
+// as a single frame.
+func DecodeAPNG(r io.Reader) ([]colorart.Frame, error) {
+	return DecodeAPNGLimit(r, loader.DefaultMaxPixels)
+}
+
+// DecodeAPNGLimit decodes every frame of an animated PNG like DecodeAPNG.
+// Every frame is a copy of the whole canvas, so it fails with an error
+// wrapping loader.ErrTooLarge before decoding if the frames together have
+// more than maxPixels pixels.
+func DecodeAPNGLimit(r io.Reader, maxPixels int64) ([]colorart.Frame, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, err := readChunks(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var ihdr []byte
+	var shared []pngChunk
+	var frames []*apngFrame
+	var cur *apngFrame
+	animated := false
+	seenIDAT := false
+
+	for _, c := range chunks {
+		switch c.typ {
+		case "IHDR":
+			ihdr = c.data
+		case "acTL":
+			animated = true
+		case "fcTL":
+			if cur, err = parseFrameControl(c.data); err != nil {
+				return nil, err
+			}
+			frames = append(frames, cur)
+		case "IDAT":
+			seenIDAT = true
+			// the default image is only a frame if a fcTL precedes it
+			if cur != nil {
+				cur.data = append(cur.data, c.data...)
+			}
+		case "fdAT":
+			if cur == nil || len(c.data) < 4 {
+				return nil, ErrFormat
+			}
+			cur.data = append(cur.data, c.data[4:]...)
+		case "IEND":
+		default:
+			// palette, transparency and color space chunks apply to every frame
+			if !seenIDAT && cur == nil {
+				shared = append(shared, c)
+			}
+		}
+	}
+
+	if len(ihdr) != 13 {
+		return nil, ErrFormat
+	}
+
+	width := int(binary.BigEndian.Uint32(ihdr[0:]))
+	height := int(binary.BigEndian.Uint32(ihdr[4:]))
+	if !animated || len(frames) == 0 {
+		if err := loader.CheckSize(width, height, 1, maxPixels); err != nil {
+			return nil, err
+		}
+
+		img, err := png.Decode(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		return []colorart.Frame{{Image: img}}, nil
+	}
+
+	if err := loader.CheckSize(width, height, len(frames), maxPixels); err != nil {
+		return nil, err
+	}
+
+	bounds := image.Rect(0, 0, width, height)
+	for _, f := range frames {
+		if !image.Rect(f.x, f.y, f.x+f.width, f.y+f.height).In(bounds) {
+			return nil, ErrFormat
+		}
+	}
+
+	canvas := image.NewRGBA(bounds)
+	result := make([]colorart.Frame, 0, len(frames))
+	for i, f := range frames {
+		img, err := decodeFrame(ihdr, shared, f)
+		if err != nil {
+			return nil, err
+		}
+
+		rect := image.Rect(f.x, f.y, f.x+f.width, f.y+f.height)
+
+		dispose := f.dispose
+		if i == 0 && dispose == apngDisposePrevious {
+			dispose = apngDisposeBackground
+		}
+
+		var previous *image.RGBA
+		if dispose == apngDisposePrevious {
+			previous = cloneImage(canvas).(*image.RGBA)
+		}
+
+		op := draw.Over
+		if f.blend == apngBlendSource {
+			op = draw.Src
+		}
+		draw.Draw(canvas, rect, img, img.Bounds().Min, op)
+
+		result = append(result, colorart.Frame{Image: cloneImage(canvas), Delay: f.delay})
+
+		switch dispose {
+		case apngDisposeBackground:
+			draw.Draw(canvas, rect, image.Transparent, image.Point{}, draw.Src)
+		case apngDisposePrevious:
+			canvas = previous
+		}
+	}
+
+	return result, nil
+}
+
+func readChunks(raw []byte) ([]pngChunk, error) {
+	if len(raw) < len(pngSignature) || string(raw[:len(pngSignature)]) != pngSignature {
+		return nil, ErrFormat
+	}
+	raw = raw[len(pngSignature):]
+
+	var chunks []pngChunk
+	for len(raw) > 0 {
+		if len(raw) < 12 {
+			return nil, ErrFormat
+		}
+		n := binary.BigEndian.Uint32(raw)
+		if uint64(n) > uint64(len(raw)-12) {
+			return nil, ErrFormat
+		}
+		chunks = append(chunks, pngChunk{string(raw[4:8]), raw[8 : 8+n]})
+		raw = raw[12+n:]
+	}
+
+	return chunks, nil
+}
+
+func parseFrameControl(data []byte) (*apngFrame, error) {
+	if len(data) != 26 {
+		return nil, ErrFormat
+	}
+
+	num := time.Duration(binary.BigEndian.Uint16(data[20:]))
+	den := time.Duration(binary.BigEndian.Uint16(data[22:]))
+	if den == 0 {
+		den = 100
+	}
+
+	return &apngFrame{
+		width:   int(binary.BigEndian.Uint32(data[4:])),
+		height:  int(binary.BigEndian.Uint32(data[8:])),
+		x:       int(binary.BigEndian.Uint32(data[12:])),
+		y:       int(binary.BigEndian.Uint32(data[16:])),
+		delay:   num * time.Second / den,
+		dispose: data[24],
+		blend:   data[25],
+	}, nil
+}
+
+// decodeFrame decodes a frame by wrapping its data in a standalone PNG
+// with the frame's dimensions.
+func decodeFrame(ihdr []byte, shared []pngChunk, f *apngFrame) (image.Image, error) {
+	var buf bytes.Buffer
+	buf.WriteString(pngSignature)
+
+	hdr := append([]byte(nil), ihdr...)
+	binary.BigEndian.PutUint32(hdr[0:], uint32(f.width))
+	binary.BigEndian.PutUint32(hdr[4:], uint32(f.height))
+	writeChunk(&buf, "IHDR", hdr)
+
+	for _, c := range shared {
+		writeChunk(&buf, c.typ, c.data)
+	}
+
+	writeChunk(&buf, "IDAT", f.data)
+	writeChunk(&buf, "IEND", nil)
+
+	return png.Decode(&buf)
+}
+
+func writeChunk(w *bytes.Buffer, typ string, data []byte) {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(data)))
+	w.Write(n[:])
+
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+
+	w.WriteString(typ)
+	w.Write(data)
+	binary.BigEndian.PutUint32(n[:], crc.Sum32())
+	w.Write(n[:])
+}
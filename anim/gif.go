@@ -0,0 +1,227 @@
+// Package anim decodes animated GIF and PNG (APNG) images into the fully
+// composited frames analyzed by colorart.AnalyzeFrames.
+package anim
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"time"
+
+	"github.com/sspencer/colorart"
+	"github.com/sspencer/colorart/loader"
+)
+
+// DecodeGIF decodes every frame of a GIF within loader.DefaultMaxPixels.
+// When all frames share one palette they are composited into
+// *image.Paletted frames, which colorart reads fastest, otherwise into
+// *image.RGBA frames.
+func DecodeGIF(r io.Reader) ([]colorart.Frame, error) {
+	return DecodeGIFLimit(r, loader.DefaultMaxPixels)
+}
+
+// DecodeGIFLimit decodes every frame of a GIF like DecodeGIF.  Every frame
+// is a copy of the whole canvas, so it fails with an error wrapping
+// loader.ErrTooLarge before decoding if the frames together have more
+// than maxPixels pixels.
+func DecodeGIFLimit(r io.Reader, maxPixels int64) ([]colorart.Frame, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// count the frames without decompressing them
+	bounds, n := scanGIF(raw)
+	if err := loader.CheckSize(bounds.Dx(), bounds.Dy(), n, maxPixels); err != nil {
+		return nil, err
+	}
+
+	g, err := gif.DecodeAll(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds = image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() && len(g.Image) > 0 {
+		bounds = g.Image[0].Bounds()
+	}
+
+	var canvas draw.Image
+	if pal, ok := sharedPalette(g); ok {
+		canvas = image.NewPaletted(bounds, pal)
+	} else {
+		canvas = image.NewRGBA(bounds)
+	}
+	clearRect(canvas, bounds, g.BackgroundIndex)
+
+	frames := make([]colorart.Frame, 0, len(g.Image))
+	var previous draw.Image
+
+	for i, src := range g.Image {
+		disposal := byte(gif.DisposalNone)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = cloneImage(canvas)
+		}
+
+		if p, ok := canvas.(*image.Paletted); ok {
+			drawPaletted(p, src)
+		} else {
+			draw.Draw(canvas, src.Bounds(), src, src.Bounds().Min, draw.Over)
+		}
+
+		delay := time.Duration(0)
+		if i < len(g.Delay) {
+			delay = time.Duration(g.Delay[i]) * 10 * time.Millisecond
+		}
+		frames = append(frames, colorart.Frame{Image: cloneImage(canvas), Delay: delay})
+
+		switch disposal {
+		case gif.DisposalBackground:
+			clearRect(canvas, src.Bounds(), g.BackgroundIndex)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return frames, nil
+}
+
+// scanGIF returns the canvas of a GIF and the number of frames it has,
+// reading only the block structure.  Scanning stops at anything it doesn't
+// understand, where gif.DecodeAll fails too.  Like DecodeGIFLimit, an empty
+// logical screen takes the bounds of the first frame.
+func scanGIF(raw []byte) (bounds image.Rectangle, frames int) {
+	const (
+		headerLen     = 6 + 7 // signature, version, logical screen descriptor
+		descriptorLen = 9
+		colorTable    = 0x80
+	)
+
+	if len(raw) < headerLen || string(raw[:3]) != "GIF" {
+		return image.Rectangle{}, 0
+	}
+	le16 := func(b []byte) int { return int(b[0]) | int(b[1])<<8 }
+	tableLen := func(flags byte) int {
+		if flags&colorTable == 0 {
+			return 0
+		}
+		return 3 << (flags&7 + 1)
+	}
+	// skipBlocks returns the offset after the data sub-blocks at i, or -1.
+	skipBlocks := func(i int) int {
+		for i < len(raw) {
+			n := int(raw[i])
+			i += 1 + n
+			if n == 0 {
+				return i
+			}
+		}
+		return -1
+	}
+
+	bounds = image.Rect(0, 0, le16(raw[6:]), le16(raw[8:]))
+	i := headerLen + tableLen(raw[10])
+	for i >= 0 && i < len(raw) {
+		switch raw[i] {
+		case 0x21: // extension: label and data sub-blocks
+			i = skipBlocks(i + 2)
+		case 0x2c: // image: descriptor, color table, LZW code size and data
+			if i+1+descriptorLen > len(raw) {
+				return bounds, frames
+			}
+			d := raw[i+1:]
+			if frames == 0 && bounds.Empty() {
+				x, y := le16(d), le16(d[2:])
+				bounds = image.Rect(x, y, x+le16(d[4:]), y+le16(d[6:]))
+			}
+			frames++
+			i = skipBlocks(i + 1 + descriptorLen + tableLen(d[8]) + 1)
+		default: // the trailer, or something DecodeAll rejects
+			return bounds, frames
+		}
+	}
+	return bounds, frames
+}
+
+// sharedPalette returns the palette used by every frame, if there is one.
+func sharedPalette(g *gif.GIF) (color.Palette, bool) {
+	if len(g.Image) == 0 {
+		return nil, false
+	}
+
+	pal := g.Image[0].Palette
+	for _, img := range g.Image[1:] {
+		if len(img.Palette) != len(pal) {
+			return nil, false
+		}
+		for i := range pal {
+			if img.Palette[i] != pal[i] {
+				return nil, false
+			}
+		}
+	}
+
+	return pal, int(g.BackgroundIndex) < len(pal)
+}
+
+// clearRect fills r with the background color of a paletted canvas, or
+// makes it transparent on any other canvas.
+func clearRect(canvas draw.Image, r image.Rectangle, bg uint8) {
+	p, ok := canvas.(*image.Paletted)
+	if !ok {
+		draw.Draw(canvas, r, image.Transparent, image.Point{}, draw.Src)
+		return
+	}
+
+	r = r.Intersect(p.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		i := p.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			p.Pix[i] = bg
+			i++
+		}
+	}
+}
+
+// drawPaletted copies the opaque pixels of src onto p, which uses the
+// same palette.
+func drawPaletted(p *image.Paletted, src *image.Paletted) {
+	transparent := make([]bool, len(src.Palette))
+	for i, c := range src.Palette {
+		_, _, _, a := c.RGBA()
+		transparent[i] = a == 0
+	}
+
+	r := src.Rect.Intersect(p.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			k := src.Pix[src.PixOffset(x, y)]
+			if int(k) < len(transparent) && !transparent[k] {
+				p.Pix[p.PixOffset(x, y)] = k
+			}
+		}
+	}
+}
+
+func cloneImage(img draw.Image) draw.Image {
+	switch img := img.(type) {
+	case *image.Paletted:
+		c := *img
+		c.Pix = append([]uint8(nil), img.Pix...)
+		return &c
+	case *image.RGBA:
+		c := *img
+		c.Pix = append([]uint8(nil), img.Pix...)
+		return &c
+	}
+
+	c := image.NewRGBA(img.Bounds())
+	draw.Draw(c, c.Rect, img, c.Rect.Min, draw.Src)
+	return c
+}
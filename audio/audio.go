@@ -0,0 +1,167 @@
+// Package audio extracts embedded cover art from audio files: ID3v2 APIC
+// frames (MP3), FLAC PICTURE blocks, MP4/M4A covr atoms and Ogg Vorbis or
+// Opus METADATA_BLOCK_PICTURE comments.
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"os"
+)
+
+// FrontCover is the picture type of the front cover (ID3v2 and FLAC).
+const FrontCover = 3
+
+// largest picture, or metadata block holding one, that will be read
+const maxPictureSize = 64 << 20
+
+var (
+	// ErrNoPicture is returned when a file has no embedded picture.
+	ErrNoPicture = errors.New("audio: no embedded picture")
+
+	// ErrFormat is returned for unrecognized or malformed files.
+	ErrFormat = errors.New("audio: unrecognized or malformed file")
+)
+
+// Picture is an embedded picture.
+type Picture struct {
+	MIMEType    string
+	Type        byte
+	Description string
+	Data        []byte
+}
+
+// ReadPictures returns every picture embedded in an MP3, FLAC, MP4/M4A or
+// Ogg file, in the order they are stored.
+func ReadPictures(r io.ReadSeeker) ([]Picture, error) {
+	var magic [8]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, ErrFormat
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic[:], []byte("ID3")):
+		return readID3(r)
+	case bytes.HasPrefix(magic[:], []byte("fLaC")):
+		return readFLAC(r)
+	case bytes.HasPrefix(magic[:], []byte("OggS")):
+		return readOgg(r)
+	case string(magic[4:8]) == "ftyp":
+		return readMP4(r)
+	}
+
+	return nil, ErrFormat
+}
+
+// Cover returns the front cover, or the first picture if none is marked
+// as the front cover.
+func Cover(pictures []Picture) (*Picture, error) {
+	if len(pictures) == 0 {
+		return nil, ErrNoPicture
+	}
+
+	for i := range pictures {
+		if pictures[i].Type == FrontCover {
+			return &pictures[i], nil
+		}
+	}
+
+	return &pictures[0], nil
+}
+
+// DecodeCover decodes the cover of the audio file r.  The image format
+// must be registered with the image package (import _ "image/jpeg").
+func DecodeCover(r io.ReadSeeker) (image.Image, error) {
+	pictures, err := ReadPictures(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := Cover(pictures)
+	if err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(p.Data))
+	return img, err
+}
+
+// DecodeCoverFile decodes the cover of the named audio file.
+func DecodeCoverFile(filename string) (image.Image, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return DecodeCover(file)
+}
+
+// parsePictureBlock parses the FLAC METADATA_BLOCK_PICTURE structure, also
+// used (base64 encoded) by Ogg comments.
+func parsePictureBlock(b []byte) (Picture, error) {
+	var p Picture
+
+	next := func() ([]byte, bool) {
+		if len(b) < 4 {
+			return nil, false
+		}
+		n := binary.BigEndian.Uint32(b)
+		if uint64(n) > uint64(len(b)-4) {
+			return nil, false
+		}
+		v := b[4 : 4+n]
+		b = b[4+n:]
+		return v, true
+	}
+
+	if len(b) < 4 {
+		return p, ErrFormat
+	}
+	p.Type = byte(binary.BigEndian.Uint32(b))
+	b = b[4:]
+
+	mime, ok := next()
+	if !ok {
+		return p, ErrFormat
+	}
+	desc, ok := next()
+	if !ok {
+		return p, ErrFormat
+	}
+
+	// width, height, depth and number of colors
+	if len(b) < 16 {
+		return p, ErrFormat
+	}
+	b = b[16:]
+
+	data, ok := next()
+	if !ok {
+		return p, ErrFormat
+	}
+
+	p.MIMEType = string(mime)
+	p.Description = string(desc)
+	p.Data = data
+	return p, nil
+}
+
+// readFull reads n bytes from r, refusing sizes over maxPictureSize.
+func readFull(r io.Reader, n int64) ([]byte, error) {
+	if n < 0 || n > maxPictureSize {
+		return nil, ErrFormat
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, ErrFormat
+	}
+	return b, nil
+}
@@ -0,0 +1,161 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func testPNG(t *testing.T) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	img.SetNRGBA(1, 1, color.NRGBA{200, 30, 30, 255})
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func pictureBlock(data []byte) []byte {
+	var b []byte
+	b = binary.BigEndian.AppendUint32(b, FrontCover)
+	b = binary.BigEndian.AppendUint32(b, 9)
+	b = append(b, "image/png"...)
+	b = binary.BigEndian.AppendUint32(b, 0)
+	b = append(b, make([]byte, 16)...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+	return append(b, data...)
+}
+
+func id3File(data []byte) []byte {
+	body := []byte{encLatin1}
+	body = append(body, "image/png\x00"...)
+	body = append(body, FrontCover)
+	body = append(body, "cover\x00"...)
+	body = append(body, data...)
+
+	frame := []byte("TIT2")
+	frame = binary.BigEndian.AppendUint32(frame, 3)
+	frame = append(frame, 0, 0, encLatin1, 'h', 'i')
+	frame = append(frame, "APIC"...)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(body)))
+	frame = append(frame, 0, 0)
+	frame = append(frame, body...)
+	frame = append(frame, make([]byte, 32)...) // padding
+
+	n := len(frame)
+	b := []byte{'I', 'D', '3', 3, 0, 0, byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+	b = append(b, frame...)
+	return append(b, 0xff, 0xfb, 0x90, 0x00) // start of the mpeg audio
+}
+
+func flacFile(data []byte) []byte {
+	b := []byte("fLaC")
+	b = append(b, 0, 0, 0, 34) // STREAMINFO
+	b = append(b, make([]byte, 34)...)
+	block := pictureBlock(data)
+	b = append(b, 0x80|flacPicture, byte(len(block)>>16), byte(len(block)>>8), byte(len(block)))
+	return append(b, block...)
+}
+
+func atom(typ string, body ...[]byte) []byte {
+	n := 8
+	for _, b := range body {
+		n += len(b)
+	}
+	a := binary.BigEndian.AppendUint32(nil, uint32(n))
+	a = append(a, typ...)
+	for _, b := range body {
+		a = append(a, b...)
+	}
+	return a
+}
+
+func mp4File(data []byte) []byte {
+	covr := atom("covr", atom("data", []byte{0, 0, 0, mp4PNG, 0, 0, 0, 0}, data))
+	meta := atom("meta", []byte{0, 0, 0, 0}, atom("hdlr", make([]byte, 25)), atom("ilst", covr))
+	return append(append(atom("ftyp", []byte("M4A \x00\x00\x00\x00")), atom("mdat", make([]byte, 100))...),
+		atom("moov", atom("mvhd", make([]byte, 100)), atom("udta", meta))...)
+}
+
+func oggPage(serial uint32, packet []byte) []byte {
+	var segs []byte
+	n := len(packet)
+	for ; n >= 255; n -= 255 {
+		segs = append(segs, 255)
+	}
+	segs = append(segs, byte(n))
+
+	b := []byte("OggS\x00\x00")
+	b = append(b, make([]byte, 8)...)
+	b = binary.LittleEndian.AppendUint32(b, serial)
+	b = append(b, make([]byte, 8)...)
+	b = append(b, byte(len(segs)))
+	b = append(b, segs...)
+	return append(b, packet...)
+}
+
+func oggFile(data []byte) []byte {
+	comment := "METADATA_BLOCK_PICTURE=" + base64.StdEncoding.EncodeToString(pictureBlock(data))
+
+	packet := []byte("\x03vorbis")
+	packet = binary.LittleEndian.AppendUint32(packet, 4)
+	packet = append(packet, "test"...)
+	packet = binary.LittleEndian.AppendUint32(packet, 1)
+	packet = binary.LittleEndian.AppendUint32(packet, uint32(len(comment)))
+	packet = append(packet, comment...)
+	packet = append(packet, 1)
+
+	b := oggPage(7, []byte("\x01vorbis identification"))
+	b = append(b, oggPage(9, []byte("other stream"))...)
+	return append(b, oggPage(7, packet)...)
+}
+
+func TestDecodeCover(t *testing.T) {
+	data := testPNG(t)
+
+	for name, file := range map[string][]byte{
+		"id3":  id3File(data),
+		"flac": flacFile(data),
+		"mp4":  mp4File(data),
+		"ogg":  oggFile(data),
+	} {
+		pictures, err := ReadPictures(bytes.NewReader(file))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		p, err := Cover(pictures)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if p.MIMEType != "image/png" || !bytes.Equal(p.Data, data) {
+			t.Errorf("%s: picture should be the PNG, not %q (%d bytes)", name, p.MIMEType, len(p.Data))
+		}
+
+		img, err := DecodeCover(bytes.NewReader(file))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if img.Bounds().Dx() != 4 {
+			t.Errorf("%s: decoded cover should be 4 pixels wide", name)
+		}
+	}
+}
+
+func TestNoPicture(t *testing.T) {
+	flac := flacFile(nil)[:42]
+	flac[4] |= 0x80 // STREAMINFO is the last block
+	if _, err := DecodeCover(bytes.NewReader(flac)); err != ErrNoPicture {
+		t.Errorf("FLAC without pictures should fail with ErrNoPicture, not %v", err)
+	}
+	if _, err := ReadPictures(bytes.NewReader([]byte("not an audio file"))); err != ErrFormat {
+		t.Errorf("unknown file should fail with ErrFormat, not %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package audio
+
+import (
+	"io"
+)
+
+const flacPicture = 6
+
+// readFLAC reads the PICTURE blocks of a FLAC file's metadata.
+func readFLAC(r io.ReadSeeker) ([]Picture, error) {
+	if _, err := r.Seek(4, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var pictures []Picture
+	for {
+		var hdr [4]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return nil, ErrFormat
+		}
+
+		last := hdr[0]&0x80 != 0
+		typ := hdr[0] & 0x7f
+		n := int64(hdr[1])<<16 | int64(hdr[2])<<8 | int64(hdr[3])
+
+		if typ == flacPicture {
+			b, err := readFull(r, n)
+			if err != nil {
+				return nil, err
+			}
+			p, err := parsePictureBlock(b)
+			if err != nil {
+				return nil, err
+			}
+			pictures = append(pictures, p)
+		} else if _, err := r.Seek(n, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+
+		if last {
+			return pictures, nil
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// ID3v2 header and frame flags
+const (
+	id3Unsync       = 0x80
+	id3Extended     = 0x40
+	id3v23Compr     = 0x80
+	id3v23Crypt     = 0x40
+	id3v23Group     = 0x20
+	id3FrameGroup   = 0x40
+	id3FrameCompr   = 0x08
+	id3FrameCrypt   = 0x04
+	id3FrameUnsync  = 0x02
+	id3FrameDataLen = 0x01
+)
+
+// ID3v2 text encodings
+const (
+	encLatin1  = 0
+	encUTF16   = 1
+	encUTF16BE = 2
+	encUTF8    = 3
+)
+
+func syncsafe(b []byte) int64 {
+	return int64(b[0]&0x7f)<<21 | int64(b[1]&0x7f)<<14 | int64(b[2]&0x7f)<<7 | int64(b[3]&0x7f)
+}
+
+// removeUnsync undoes ID3v2 unsynchronisation, which inserts a 0x00 after
+// every 0xff.
+func removeUnsync(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte{0xff, 0x00}, []byte{0xff})
+}
+
+// readID3 reads the APIC (v2.3, v2.4) or PIC (v2.2) frames of an ID3v2 tag.
+func readID3(r io.Reader) ([]Picture, error) {
+	var hdr [10]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, ErrFormat
+	}
+
+	version := hdr[3]
+	flags := hdr[5]
+	if version < 2 || version > 4 {
+		return nil, ErrFormat
+	}
+
+	tag, err := readFull(r, syncsafe(hdr[6:]))
+	if err != nil {
+		return nil, err
+	}
+
+	// before v2.4 the whole tag is unsynchronised
+	if flags&id3Unsync != 0 && version < 4 {
+		tag = removeUnsync(tag)
+	}
+
+	if flags&id3Extended != 0 && version > 2 {
+		if len(tag) < 4 {
+			return nil, ErrFormat
+		}
+		n := int64(binary.BigEndian.Uint32(tag)) + 4
+		if version == 4 {
+			n = syncsafe(tag)
+		}
+		if n > int64(len(tag)) {
+			return nil, ErrFormat
+		}
+		tag = tag[n:]
+	}
+
+	idLen, hdrLen := 4, 10
+	if version == 2 {
+		idLen, hdrLen = 3, 6
+	}
+
+	var pictures []Picture
+	for len(tag) >= hdrLen && tag[0] != 0 {
+		id := string(tag[:idLen])
+
+		var size int64
+		var frameFlags byte
+		switch version {
+		case 2:
+			size = int64(tag[3])<<16 | int64(tag[4])<<8 | int64(tag[5])
+		case 3:
+			size = int64(binary.BigEndian.Uint32(tag[4:]))
+			frameFlags = tag[9]
+		case 4:
+			size = syncsafe(tag[4:])
+			frameFlags = tag[9]
+		}
+
+		if size > int64(len(tag)-hdrLen) {
+			return nil, ErrFormat
+		}
+		data := tag[hdrLen : int64(hdrLen)+size]
+		tag = tag[int64(hdrLen)+size:]
+
+		if id != "APIC" && id != "PIC" {
+			continue
+		}
+
+		// compressed or encrypted pictures are skipped
+		if version == 3 && frameFlags&(id3v23Compr|id3v23Crypt) != 0 || version == 4 && frameFlags&(id3FrameCompr|id3FrameCrypt) != 0 {
+			continue
+		}
+
+		// skip the group identifier
+		if version == 3 && frameFlags&id3v23Group != 0 || version == 4 && frameFlags&id3FrameGroup != 0 {
+			if len(data) < 1 {
+				return nil, ErrFormat
+			}
+			data = data[1:]
+		}
+
+		if version == 4 {
+			if frameFlags&id3FrameDataLen != 0 {
+				if len(data) < 4 {
+					return nil, ErrFormat
+				}
+				data = data[4:]
+			}
+			if frameFlags&id3FrameUnsync != 0 {
+				data = removeUnsync(data)
+			}
+		}
+
+		p, err := parseAPIC(data, version == 2)
+		if err != nil {
+			return nil, err
+		}
+		pictures = append(pictures, p)
+	}
+
+	return pictures, nil
+}
+
+// parseAPIC parses the body of an APIC frame, or of a v2.2 PIC frame,
+// which has a 3 letter image format instead of a MIME type.
+func parseAPIC(b []byte, v22 bool) (Picture, error) {
+	var p Picture
+	if len(b) < 2 {
+		return p, ErrFormat
+	}
+
+	enc := b[0]
+	b = b[1:]
+
+	if v22 {
+		if len(b) < 3 {
+			return p, ErrFormat
+		}
+		p.MIMEType = "image/" + strings.ToLower(string(b[:3]))
+		if p.MIMEType == "image/jpg" {
+			p.MIMEType = "image/jpeg"
+		}
+		b = b[3:]
+	} else {
+		i := bytes.IndexByte(b, 0)
+		if i < 0 {
+			return p, ErrFormat
+		}
+		p.MIMEType = string(b[:i])
+		b = b[i+1:]
+	}
+
+	if len(b) < 1 {
+		return p, ErrFormat
+	}
+	p.Type = b[0]
+	b = b[1:]
+
+	desc, rest, ok := splitText(b, enc)
+	if !ok {
+		return p, ErrFormat
+	}
+
+	p.Description = desc
+	p.Data = rest
+	return p, nil
+}
+
+// splitText splits a terminated string in the given encoding from b.
+func splitText(b []byte, enc byte) (string, []byte, bool) {
+	if enc != encUTF16 && enc != encUTF16BE {
+		i := bytes.IndexByte(b, 0)
+		if i < 0 {
+			return "", nil, false
+		}
+		if enc == encLatin1 {
+			return latin1(b[:i]), b[i+1:], true
+		}
+		return string(b[:i]), b[i+1:], true
+	}
+
+	for i := 0; i+1 < len(b); i += 2 {
+		if b[i] == 0 && b[i+1] == 0 {
+			return decodeUTF16(b[:i], enc == encUTF16BE), b[i+2:], true
+		}
+	}
+	return "", nil, false
+}
+
+func latin1(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+// decodeUTF16 decodes UTF-16 with an optional byte order mark, big endian
+// if there is none and be is set.
+func decodeUTF16(b []byte, be bool) string {
+	order := binary.ByteOrder(binary.LittleEndian)
+	if be {
+		order = binary.BigEndian
+	}
+	if len(b) >= 2 {
+		switch {
+		case b[0] == 0xff && b[1] == 0xfe:
+			order, b = binary.LittleEndian, b[2:]
+		case b[0] == 0xfe && b[1] == 0xff:
+			order, b = binary.BigEndian, b[2:]
+		}
+	}
+
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = order.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
@@ -0,0 +1,121 @@
+package audio
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// mp4 data atom type indicators of covr images
+const (
+	mp4JPEG = 13
+	mp4PNG  = 14
+	mp4BMP  = 27
+)
+
+// readMP4 reads the images of the moov/udta/meta/ilst/covr atom, skipping
+// over everything else (including the media data) without reading it.
+func readMP4(r io.ReadSeeker) ([]Picture, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var pictures []Picture
+	err = walkAtoms(r, 0, end, func(typ string, size int64) (bool, error) {
+		switch typ {
+		case "moov", "udta", "ilst", "covr":
+			return true, nil
+		case "meta":
+			// meta is a full box: skip its version and flags
+			_, err := r.Seek(4, io.SeekCurrent)
+			return true, err
+		case "data":
+			b, err := readFull(r, size)
+			if err != nil {
+				return false, err
+			}
+			if p, ok := parseCovrData(b); ok {
+				pictures = append(pictures, p)
+			}
+		}
+		return false, nil
+	})
+
+	return pictures, err
+}
+
+// walkAtoms calls fn with the type and body size of each atom from start
+// to end, with r at the start of the atom body.  Atoms fn returns true
+// for are walked into.
+func walkAtoms(r io.ReadSeeker, start, end int64, fn func(typ string, size int64) (bool, error)) error {
+	for start+8 <= end {
+		var hdr [16]byte
+		if _, err := io.ReadFull(r, hdr[:8]); err != nil {
+			return ErrFormat
+		}
+
+		size := int64(binary.BigEndian.Uint32(hdr[:]))
+		typ := string(hdr[4:8])
+		body := start + 8
+
+		switch size {
+		case 0:
+			size = end - start
+		case 1:
+			if _, err := io.ReadFull(r, hdr[8:]); err != nil {
+				return ErrFormat
+			}
+			size = int64(binary.BigEndian.Uint64(hdr[8:]))
+			body += 8
+		}
+
+		if size < body-start || start+size > end {
+			return ErrFormat
+		}
+
+		into, err := fn(typ, start+size-body)
+		if err != nil {
+			return err
+		}
+
+		if into {
+			cur, err := r.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return err
+			}
+			if err := walkAtoms(r, cur, start+size, fn); err != nil {
+				return err
+			}
+		}
+
+		start += size
+		if _, err := r.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseCovrData parses a covr data atom body: a type indicator, a locale
+// and the image.
+func parseCovrData(b []byte) (Picture, bool) {
+	if len(b) < 8 {
+		return Picture{}, false
+	}
+
+	p := Picture{Type: FrontCover, Data: b[8:]}
+	switch binary.BigEndian.Uint32(b) & 0xffffff {
+	case mp4JPEG:
+		p.MIMEType = "image/jpeg"
+	case mp4PNG:
+		p.MIMEType = "image/png"
+	case mp4BMP:
+		p.MIMEType = "image/bmp"
+	}
+
+	return p, true
+}
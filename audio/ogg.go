@@ -0,0 +1,178 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+// the comment header is at most this many packets into the stream
+const oggMaxPackets = 4
+
+// readOgg reads the METADATA_BLOCK_PICTURE (and legacy COVERART) comments
+// of the first Vorbis or Opus stream of an Ogg file.
+func readOgg(r io.Reader) ([]Picture, error) {
+	pr := &oggPackets{r: bufio.NewReader(r)}
+
+	for i := 0; i < oggMaxPackets; i++ {
+		packet, err := pr.next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case bytes.HasPrefix(packet, []byte("\x03vorbis")):
+			return parseComments(packet[7:])
+		case bytes.HasPrefix(packet, []byte("OpusTags")):
+			return parseComments(packet[8:])
+		}
+	}
+
+	return nil, ErrNoPicture
+}
+
+// oggPackets reassembles the packets of the first logical stream.
+type oggPackets struct {
+	r       *bufio.Reader
+	serial  uint32
+	started bool
+	segs    []byte
+	pending []byte
+}
+
+func (p *oggPackets) next() ([]byte, error) {
+	var packet []byte
+	for {
+		for len(p.segs) > 0 {
+			n := int(p.segs[0])
+			p.segs = p.segs[1:]
+			if n > len(p.pending) {
+				return nil, ErrFormat
+			}
+			packet = append(packet, p.pending[:n]...)
+			p.pending = p.pending[n:]
+			if len(packet) > maxPictureSize*2 {
+				return nil, ErrFormat
+			}
+			if n < 255 {
+				return packet, nil
+			}
+		}
+
+		if err := p.readPage(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readPage reads the next page of the first stream into segs and pending.
+func (p *oggPackets) readPage() error {
+	for {
+		var hdr [27]byte
+		if _, err := io.ReadFull(p.r, hdr[:]); err != nil {
+			return ErrFormat
+		}
+		if string(hdr[:4]) != "OggS" {
+			return ErrFormat
+		}
+
+		segs := make([]byte, hdr[26])
+		if _, err := io.ReadFull(p.r, segs); err != nil {
+			return ErrFormat
+		}
+
+		n := 0
+		for _, s := range segs {
+			n += int(s)
+		}
+		body, err := readFull(p.r, int64(n))
+		if err != nil {
+			return err
+		}
+
+		serial := binary.LittleEndian.Uint32(hdr[14:])
+		if !p.started {
+			p.serial = serial
+			p.started = true
+		}
+		if serial != p.serial {
+			continue
+		}
+
+		p.segs = segs
+		p.pending = body
+		return nil
+	}
+}
+
+// parseComments parses a Vorbis comment block (without its packet type).
+func parseComments(b []byte) ([]Picture, error) {
+	next := func() ([]byte, bool) {
+		if len(b) < 4 {
+			return nil, false
+		}
+		n := binary.LittleEndian.Uint32(b)
+		if uint64(n) > uint64(len(b)-4) {
+			return nil, false
+		}
+		v := b[4 : 4+n]
+		b = b[4+n:]
+		return v, true
+	}
+
+	if _, ok := next(); !ok { // vendor
+		return nil, ErrFormat
+	}
+	if len(b) < 4 {
+		return nil, ErrFormat
+	}
+	count := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+
+	var pictures []Picture
+	var coverArt []byte
+	coverMIME := ""
+
+	for i := uint32(0); i < count; i++ {
+		c, ok := next()
+		if !ok {
+			return nil, ErrFormat
+		}
+
+		eq := bytes.IndexByte(c, '=')
+		if eq < 0 {
+			continue
+		}
+		key, value := strings.ToUpper(string(c[:eq])), c[eq+1:]
+
+		switch key {
+		case "METADATA_BLOCK_PICTURE":
+			block, err := base64.StdEncoding.DecodeString(string(value))
+			if err != nil {
+				return nil, ErrFormat
+			}
+			p, err := parsePictureBlock(block)
+			if err != nil {
+				return nil, err
+			}
+			pictures = append(pictures, p)
+		case "COVERART":
+			data, err := base64.StdEncoding.DecodeString(string(value))
+			if err != nil {
+				return nil, ErrFormat
+			}
+			coverArt = data
+		case "COVERARTMIME":
+			coverMIME = string(value)
+		}
+	}
+
+	if coverArt != nil {
+		pictures = append(pictures, Picture{MIMEType: coverMIME, Type: FrontCover, Data: coverArt})
+	}
+
+	return pictures, nil
+}
@@ -0,0 +1,137 @@
+package colorart
+
+import (
+	"image"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// BatchItem is an image to analyze as part of a batch.  If Image is nil,
+// the file named by Filename is opened and decoded with image.Decode, so
+// the caller must register the formats it expects (import _ "image/jpeg").
+type BatchItem struct {
+	Filename string
+	Image    image.Image
+}
+
+// BatchResult is the outcome of analyzing a single BatchItem.
+type BatchResult struct {
+	// Index is the position of the item in the batch or stream.
+	Index    int
+	Filename string
+	Result   Result
+	Err      error
+}
+
+// BatchOptions controls AnalyzeBatch and AnalyzeStream.
+type BatchOptions struct {
+	// Workers is the number of images analyzed at the same time.
+	// 0 uses runtime.GOMAXPROCS(0).
+	Workers int
+
+	// Options is used for every image.  A nil Options counts each image on
+	// its worker goroutine, since the parallelism is across images.
+	Options *Options
+}
+
+// AnalyzeBatch analyzes items across a pool of workers and returns the
+// results in the same order as items.
+func AnalyzeBatch(items []BatchItem, opts *BatchOptions) []BatchResult {
+	in := make(chan BatchItem)
+	go func() {
+		for _, item := range items {
+			in <- item
+		}
+		close(in)
+	}()
+
+	results := make([]BatchResult, len(items))
+	for r := range AnalyzeStream(in, opts) {
+		results[r.Index] = r
+	}
+
+	return results
+}
+
+// AnalyzeStream analyzes each item received on in and sends its result on
+// the returned channel as soon as it completes.  The returned channel is
+// closed once in is closed and every item has been analyzed.
+func AnalyzeStream(in <-chan BatchItem, opts *BatchOptions) <-chan BatchResult {
+	var o BatchOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	workers := o.Workers
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	imgOpts := &Options{Concurrency: 1}
+	if o.Options != nil {
+		imgOpts = o.Options
+	}
+
+	type indexed struct {
+		index int
+		item  BatchItem
+	}
+
+	jobs := make(chan indexed)
+	out := make(chan BatchResult, workers)
+
+	go func() {
+		i := 0
+		for item := range in {
+			jobs <- indexed{i, item}
+			i++
+		}
+		close(jobs)
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				out <- analyzeItem(j.index, j.item, imgOpts)
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+func analyzeItem(index int, item BatchItem, opts *Options) BatchResult {
+	r := BatchResult{Index: index, Filename: item.Filename}
+
+	img := item.Image
+	if img == nil {
+		img, r.Err = decodeFile(item.Filename)
+		if r.Err != nil {
+			return r
+		}
+	}
+
+	r.Result = AnalyzeResult(img, opts)
+	return r
+}
+
+func decodeFile(filename string) (image.Image, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	return img, err
+}
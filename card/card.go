@@ -0,0 +1,238 @@
+// Package card draws a PNG card for a cover: the cover, optionally
+// blurred, beside a panel in its background color with sample text in its
+// primary, secondary and detail colors.  Cards are drawn with image/draw
+// and a bundled bitmap font, so they need no fonts or graphics libraries.
+package card
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/sspencer/colorart"
+)
+
+// DefaultSize is the height of a card, and the width of each half, when
+// Options.Size is 0.
+const DefaultSize = 240
+
+// Options controls how a card is drawn.  The zero value (or a nil *Options)
+// draws a DefaultSize card with placeholder text.
+type Options struct {
+	// Size is the height of the card in pixels.  The cover and the panel
+	// are each Size pixels wide.
+	Size int
+
+	// Blur is the radius (standard deviation) of the blur applied to the
+	// cover, in pixels of the card.  0 leaves the cover sharp.
+	Blur float64
+
+	// Title, Subtitle and Detail are drawn in the primary, secondary and
+	// detail colors.  Empty strings use "Title", "Artist" and "Detail".
+	Title, Subtitle, Detail string
+}
+
+// Draw draws the card for img and the colors found in it.
+func Draw(img image.Image, r colorart.Result, opts *Options) *image.RGBA {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.Size <= 0 {
+		o.Size = DefaultSize
+	}
+	if o.Title == "" {
+		o.Title = "Title"
+	}
+	if o.Subtitle == "" {
+		o.Subtitle = "Artist"
+	}
+	if o.Detail == "" {
+		o.Detail = "Detail"
+	}
+
+	size := o.Size
+	dst := image.NewRGBA(image.Rect(0, 0, 2*size, size))
+	bg := &image.Uniform{nrgba(r.Background)}
+	draw.Draw(dst, dst.Bounds(), bg, image.Point{}, draw.Src)
+
+	// the cover, fit into the left square
+	cover := resize(img, size)
+	if o.Blur > 0 {
+		blur(cover, o.Blur)
+	}
+	cb := cover.Bounds()
+	at := image.Pt((size-cb.Dx())/2, (size-cb.Dy())/2)
+	draw.Draw(dst, cb.Add(at), cover, cb.Min, draw.Src)
+
+	// the text, in the right square
+	pad := size / 12
+	x, y := size+pad, pad
+	width := size - 2*pad
+	for _, line := range []struct {
+		text  string
+		c     colorart.Color
+		scale int
+	}{
+		{o.Title, r.Primary, max(1, size/80)},
+		{o.Subtitle, r.Secondary, max(1, size/120)},
+		{o.Detail, r.Detail, max(1, size/160)},
+	} {
+		drawText(dst, x, y, fit(line.text, width, line.scale), nrgba(line.c), line.scale)
+		y += (glyphHeight + 4) * line.scale
+	}
+
+	// swatches of the text colors along the bottom
+	sw := width / 3
+	for i, c := range []colorart.Color{r.Primary, r.Secondary, r.Detail} {
+		rect := image.Rect(x+i*sw, size-pad-sw/3, x+(i+1)*sw-pad/2, size-pad)
+		draw.Draw(dst, rect, &image.Uniform{nrgba(c)}, image.Point{}, draw.Src)
+	}
+
+	return dst
+}
+
+// Encode writes the card for img and the colors found in it as a PNG.
+func Encode(w io.Writer, img image.Image, r colorart.Result, opts *Options) error {
+	return png.Encode(w, Draw(img, r, opts))
+}
+
+func nrgba(c colorart.Color) color.NRGBA {
+	k := c.RGB()
+	return color.NRGBA{k[0], k[1], k[2], 255}
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// fit shortens s to fit in width pixels at scale, ending it with ...
+func fit(s string, width, scale int) string {
+	n := width / ((glyphWidth + 1) * scale)
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	if n <= 3 {
+		return string(r[:max(n, 0)])
+	}
+	return string(r[:n-3]) + "..."
+}
+
+// drawText draws s with its top left corner at x, y, each font pixel
+// scale pixels square.
+func drawText(dst draw.Image, x, y int, s string, c color.Color, scale int) {
+	src := &image.Uniform{c}
+	for _, r := range s {
+		g := glyph(r)
+		for col := 0; col < glyphWidth; col++ {
+			for row := 0; row < glyphHeight; row++ {
+				if g[col]&(1<<uint(row)) == 0 {
+					continue
+				}
+				px := image.Rect(x+col*scale, y+row*scale, x+(col+1)*scale, y+(row+1)*scale)
+				draw.Draw(dst, px, src, image.Point{}, draw.Src)
+			}
+		}
+		x += (glyphWidth + 1) * scale
+	}
+}
+
+// resize scales img to fit in a size x size square, keeping its aspect
+// ratio.  Each pixel is the average of the pixels it covers.
+func resize(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	w, h := size, size
+	if b.Dx() > b.Dy() {
+		h = max(1, size*b.Dy()/b.Dx())
+	} else if b.Dy() > b.Dx() {
+		w = max(1, size*b.Dx()/b.Dy())
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if b.Empty() {
+		return dst
+	}
+
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := max(y0+1, b.Min.Y+(y+1)*b.Dy()/h)
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := max(x0+1, b.Min.X+(x+1)*b.Dx()/w)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(bl / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+
+	return dst
+}
+
+// blur approximates a gaussian blur with standard deviation sigma by three
+// box blurs.
+func blur(img *image.RGBA, sigma float64) {
+	// width of a box whose 3 passes have the same variance
+	radius := int(math.Sqrt(4*sigma*sigma+1)-1) / 2
+	if radius < 1 {
+		radius = 1
+	}
+
+	tmp := image.NewRGBA(img.Bounds())
+	for i := 0; i < 3; i++ {
+		boxBlur(tmp, img, radius, 4, img.Stride)
+		boxBlur(img, tmp, radius, img.Stride, 4)
+	}
+}
+
+// boxBlur averages each pixel of src with the radius pixels before and
+// after it along one direction into dst.  step is the distance between
+// pixels along the blur, and across the distance between lines.
+func boxBlur(dst, src *image.RGBA, radius, step, across int) {
+	b := src.Bounds()
+	n, lines := b.Dx(), b.Dy()
+	if step != 4 {
+		n, lines = lines, n
+	}
+
+	for l := 0; l < lines; l++ {
+		start := l * across
+		for c := 0; c < 4; c++ {
+			// running sum of the window, with the edge pixels repeated
+			at := func(i int) int {
+				if i < 0 {
+					i = 0
+				} else if i >= n {
+					i = n - 1
+				}
+				return int(src.Pix[start+i*step+c])
+			}
+
+			sum := 0
+			for i := -radius; i <= radius; i++ {
+				sum += at(i)
+			}
+			for i := 0; i < n; i++ {
+				dst.Pix[start+i*step+c] = uint8(sum / (2*radius + 1))
+				sum += at(i+radius+1) - at(i-radius)
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package card
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/sspencer/colorart"
+)
+
+var (
+	red   = colorart.RGB{200, 30, 30}.Color()
+	green = colorart.RGB{20, 200, 40}.Color()
+	white = colorart.RGB{255, 255, 255}.Color()
+	blue  = colorart.RGB{0, 0, 255}.Color()
+)
+
+func TestDraw(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	for i := 0; i < len(img.Pix); i += 4 {
+		copy(img.Pix[i:], []uint8{0, 0, 255, 255})
+	}
+
+	r := colorart.Result{Background: red, Primary: green, Secondary: white, Detail: blue}
+	c := Draw(img, r, &Options{Size: 120, Title: "A very long title that will not fit"})
+
+	if c.Bounds() != image.Rect(0, 0, 240, 120) {
+		t.Fatalf("card should be 240x120, not %v", c.Bounds())
+	}
+
+	// the wide cover is centered in the left square, on the background
+	if got := c.RGBAAt(60, 60); got != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("cover should be drawn at the center of the left half, got %v", got)
+	}
+	if got := c.RGBAAt(60, 5); got != (color.RGBA{200, 30, 30, 255}) {
+		t.Errorf("cover should be letterboxed with the background color, got %v", got)
+	}
+
+	counts := make(map[color.RGBA]int)
+	for y := 0; y < 120; y++ {
+		for x := 120; x < 240; x++ {
+			counts[c.RGBAAt(x, y)]++
+		}
+	}
+	for _, col := range []color.RGBA{{200, 30, 30, 255}, {20, 200, 40, 255}, {255, 255, 255, 255}, {0, 0, 255, 255}} {
+		if counts[col] == 0 {
+			t.Errorf("panel should have pixels of %v", col)
+		}
+	}
+}
+
+func TestBlur(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	img.Set(20, 20, color.RGBA{255, 255, 255, 255})
+
+	blur(img, 3)
+
+	center, near := img.RGBAAt(20, 20), img.RGBAAt(22, 20)
+	if center.R == 255 || near.R == 0 || near.R > center.R {
+		t.Errorf("blur should spread the point, got center %v, near %v", center, near)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, image.NewGray(image.Rect(0, 0, 10, 10)), colorart.Result{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := png.DecodeConfig(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != 2*DefaultSize || cfg.Height != DefaultSize {
+		t.Errorf("card should be %dx%d, not %dx%d", 2*DefaultSize, DefaultSize, cfg.Width, cfg.Height)
+	}
+}
+
+func TestFont(t *testing.T) {
+	if g := glyph('A'); g[0] != 0x7e {
+		t.Error("A is not at its ASCII position")
+	}
+	if glyph('é') != glyph('?') {
+		t.Error("runes outside ASCII should be drawn as ?")
+	}
+	if fit("abcdefghij", 6*5, 1) != "ab..." {
+		t.Errorf("fit should shorten text, got %q", fit("abcdefghij", 6*5, 1))
+	}
+}
@@ -1,9 +1,6 @@
 package colorart
 
-import (
-	"fmt"
-	"math"
-)
+import "math"
 
 var (
 	// BlackColor convenience
@@ -21,7 +18,7 @@ type Color struct {
 
 // String returns a HTML hex code string for the color (#9a45bc)
 func (c Color) String() string {
-	return fmt.Sprintf("#%02x%02x%02x", uint8(c.R*255), uint8(c.G*255), uint8(c.B*255))
+	return c.RGB().String()
 }
 
 // RGBAToColor converts 16bit RGBA (0-65535) color into a Color (0.0 < rgb component < 1.0)
@@ -30,9 +27,39 @@ func RGBAToColor(r, g, b, a uint32) Color {
 	return Color{float64(r) / fa, float64(g) / fa, float64(b) / fa, true}
 }
 
-// StringToColor converts [3]byte array [0xff, 0x00, 0x33]) to Color
-func rgbToColor(c rgb) Color {
-	return Color{float64(c[0]) / 255.0, float64(c[1]) / 255.0, float64(c[2]) / 255.0, true}
+// RGB converts the color to the nearest 8 bit components.  Use
+// RGB().Detune() to find the key a histogram counts the color under.
+func (c Color) RGB() RGB {
+	return RGB{to8(c.R), to8(c.G), to8(c.B)}
+}
+
+// to8 rounds a 0-1 component to 0-255, clamping those out of range.
+func to8(v float64) uint8 {
+	v = math.Round(v * 255)
+	if v <= 0 {
+		return 0
+	}
+	if v >= 255 {
+		return 255
+	}
+	return uint8(v)
+}
+
+// Luminance returns the WCAG relative luminance of the color, from 0 for
+// black to 1 for white.
+func (c Color) Luminance() float64 {
+	return 0.2126*linearize(c.R) + 0.7152*linearize(c.G) + 0.0722*linearize(c.B)
+}
+
+// ContrastRatio returns the WCAG contrast ratio between two colors, from 1
+// (the same luminance) to 21 (black and white).  Text needs 4.5, or 3 when
+// large, to be readable.
+func (c Color) ContrastRatio(d Color) float64 {
+	l1, l2 := c.Luminance(), d.Luminance()
+	if l1 < l2 {
+		l1, l2 = l2, l1
+	}
+	return (l1 + 0.05) / (l2 + 0.05)
 }
 
 // IsBlackOrWhite returns true if the color is within about 90% or black or white
@@ -16,77 +16,138 @@ const (
 	colorShifter = 2
 )
 
+// Options controls how an image is analyzed.  The zero value (or a nil
+// *Options) matches the behavior of Analyze.
+type Options struct {
+	// Concurrency is the maximum number of goroutines used to count the
+	// pixels of a single image.  0 uses runtime.GOMAXPROCS(0), 1 counts
+	// on the calling goroutine.
+	Concurrency int
+
+	// Pool, if set, is shared with other Analyze calls to bound the total
+	// number of counting goroutines.
+	Pool *Pool
+
+	// KeepHistograms returns the color counts the result was chosen from
+	// in Result.Histogram and Result.EdgeHistogram.
+	KeepHistograms bool
+}
+
+// Result holds the colors found in an image.
+type Result struct {
+	Background Color `json:"background"`
+	Primary    Color `json:"primary"`
+	Secondary  Color `json:"secondary"`
+	Detail     Color `json:"detail"`
+
+	// PrimaryFallback, SecondaryFallback and DetailFallback report that no
+	// color of the image contrasted enough with the background, so black
+	// or white was used instead.
+	PrimaryFallback   bool `json:"primary_fallback,omitempty"`
+	SecondaryFallback bool `json:"secondary_fallback,omitempty"`
+	DetailFallback    bool `json:"detail_fallback,omitempty"`
+
+	// Histogram counts the detuned colors of every other pixel of every
+	// other row, and EdgeHistogram those of the left and right columns.
+	// Both are nil unless Options.KeepHistograms is set.
+	Histogram     Histogram `json:"-"`
+	EdgeHistogram Histogram `json:"-"`
+}
+
 type colorArt struct {
-	img *pixelGetter
+	img  *pixelGetter
+	opts Options
 }
 
 // Analyze an image for its main colors.
 func Analyze(img image.Image) (backgroundColor, primaryColor, secondaryColor, detailColor Color) {
+	return AnalyzeWithOptions(img, nil)
+}
+
+// AnalyzeWithOptions analyzes an image for its main colors using opts.
+func AnalyzeWithOptions(img image.Image, opts *Options) (backgroundColor, primaryColor, secondaryColor, detailColor Color) {
+	r := AnalyzeResult(img, opts)
+	return r.Background, r.Primary, r.Secondary, r.Detail
+}
+
+// AnalyzeResult analyzes an image for its main colors using opts, and
+// optionally returns the color counts they were chosen from.
+func AnalyzeResult(img image.Image, opts *Options) Result {
 	c := &colorArt{}
 	c.img = newPixelGetter(img)
+	if opts != nil {
+		c.opts = *opts
+	}
+
+	edgeColors := c.countEdgeColors()
+	imageColors := c.countImageColors()
+
+	r := selectColors(edgeColors, imageColors)
+
+	if c.opts.KeepHistograms {
+		r.Histogram = imageColors
+		r.EdgeHistogram = edgeColors
+	} else if d, ok := imageColors.(*DenseSet); ok {
+		d.release()
+	}
+
+	return r
+}
 
-	backgroundColor = c.findEdgeColor()
-	primaryColor, secondaryColor, detailColor = c.findTextColors(backgroundColor)
+// selectColors chooses the background color from the edge colors and the
+// text colors from the image colors.
+func selectColors(edgeColors, imageColors Histogram) (r Result) {
+	r.Background = findEdgeColor(edgeColors)
+	r.Primary, r.Secondary, r.Detail = findTextColors(imageColors, r.Background)
 
-	darkBackground := backgroundColor.IsDarkColor()
+	darkBackground := r.Background.IsDarkColor()
 
-	if !primaryColor.set {
+	if !r.Primary.set {
+		r.PrimaryFallback = true
 		if darkBackground {
-			primaryColor = WhiteColor
+			r.Primary = WhiteColor
 		} else {
-			primaryColor = BlackColor
+			r.Primary = BlackColor
 		}
 	}
 
-	if !secondaryColor.set {
+	if !r.Secondary.set {
+		r.SecondaryFallback = true
 		if darkBackground {
-			secondaryColor = WhiteColor
+			r.Secondary = WhiteColor
 		} else {
-			secondaryColor = BlackColor
+			r.Secondary = BlackColor
 		}
 	}
 
-	if !detailColor.set {
+	if !r.Detail.set {
+		r.DetailFallback = true
 		if darkBackground {
-			detailColor = WhiteColor
+			r.Detail = WhiteColor
 		} else {
-			detailColor = BlackColor
+			r.Detail = BlackColor
 		}
 	}
 
 	return
 }
 
-func (c *colorArt) findTextColors(backgroundColor Color) (primaryColor, secondaryColor, detailColor Color) {
-	b := c.img.imgBounds
-	imageColors := parallelize(b.Min.Y, b.Max.Y, func(ch chan CountedSet, pmin, pmax int) {
-		b := c.img.imgBounds
-		colors := NewCountedSet(10000)
-		for y := pmin; y < pmax; y += loopSkipper {
-			for x := b.Min.X; x < b.Max.X; x += loopSkipper {
-				colors.AddPixel(c.img.getPixel(x, y))
-			}
-		}
-
-		ch <- colors
-	})
-
+func findTextColors(imageColors Histogram, backgroundColor Color) (primaryColor, secondaryColor, detailColor Color) {
 	useDarkTextColor := !backgroundColor.IsDarkColor()
 	selectColors := NewCountedSet(5000)
 
-	for key, cnt := range imageColors {
+	imageColors.Range(func(key RGB, cnt int) {
 		// don't bother unless there's more than a few of the same color
 
-		curColor := rgbToColor(key).ColorWithMinimumSaturation(0.15)
+		curColor := key.Color().ColorWithMinimumSaturation(0.15)
 		if curColor.IsDarkColor() == useDarkTextColor {
 			selectColors.AddCount(key, cnt)
 		}
+	})
 
-	}
-
-	sortedColors := selectColors.SortedSet()
-	for _, e := range sortedColors {
-		curColor := rgbToColor(e.Color)
+	sortedColors := NewEntryIterator(selectColors)
+	for e, ok := sortedColors.Next(); ok; e, ok = sortedColors.Next() {
+		curColor := e.Color.Color()
 		if !primaryColor.set {
 			if curColor.IsContrastingColor(backgroundColor) {
 				primaryColor = curColor
@@ -114,8 +175,31 @@ func (c *colorArt) findTextColors(backgroundColor Color) (primaryColor, secondar
 	return
 }
 
-func (c *colorArt) findEdgeColor() Color {
+// countImageColors counts every loopSkipper'th pixel of the image.
+func (c *colorArt) countImageColors() Histogram {
+	b := c.img.imgBounds
+	return parallelize(b.Min.Y, b.Max.Y, c.opts.Concurrency, c.opts.Pool, func(ch chan Histogram, pmin, pmax int) {
+		b := c.img.imgBounds
+		colors := newPartHistogram()
+
+		// sample the same rows no matter how the image was split up
+		y := pmin
+		if r := (pmin - b.Min.Y) % loopSkipper; r != 0 {
+			y += loopSkipper - r
+		}
 
+		for ; y < pmax; y += loopSkipper {
+			for x := b.Min.X; x < b.Max.X; x += loopSkipper {
+				colors.AddPixel(c.img.getPixel(x, y))
+			}
+		}
+
+		ch <- colors
+	})
+}
+
+// countEdgeColors counts the pixels of the left and right columns.
+func (c *colorArt) countEdgeColors() Histogram {
 	edgeColors := NewCountedSet(500)
 	b := c.img.imgBounds
 	x0 := b.Min.X
@@ -125,27 +209,32 @@ func (c *colorArt) findEdgeColor() Color {
 		edgeColors.AddPixel(c.img.getPixel(x1, y))
 	}
 
-	sortedColors := edgeColors.SortedSet()
+	return edgeColors
+}
 
-	proposedEntry := sortedColors[0]
-	proposedColor := rgbToColor(proposedEntry.Color)
+func findEdgeColor(edgeColors Histogram) Color {
+	sortedColors := NewEntryIterator(edgeColors)
+
+	proposedEntry, ok := sortedColors.Next()
+	if !ok {
+		return Color{}
+	}
+	proposedColor := proposedEntry.Color.Color()
 
 	// try another color if edge is close to black or white
 	if proposedColor.IsBlackOrWhite() {
-		for i, e := range sortedColors {
-			if i == 0 {
-				// first entry is already set as "proposedEntry"
-				continue
+		for e, ok := sortedColors.Next(); ok; e, ok = sortedColors.Next() {
+			nextProposedEntry := e
+			// make sure second choice is 30% as common as first choice,
+			// which no later (less common) entry can be either
+			if float32(nextProposedEntry.Count)/float32(proposedEntry.Count) <= 0.3 {
+				break
 			}
 
-			nextProposedEntry := e
-			// make sure second choice is 30% as common as first choice
-			if float32(nextProposedEntry.Count)/float32(proposedEntry.Count) > 0.3 {
-				nextProposedColor := rgbToColor(nextProposedEntry.Color)
-				if !nextProposedColor.IsBlackOrWhite() {
-					proposedColor = nextProposedColor
-					break
-				}
+			nextProposedColor := nextProposedEntry.Color.Color()
+			if !nextProposedColor.IsBlackOrWhite() {
+				proposedColor = nextProposedColor
+				break
 			}
 		}
 	}
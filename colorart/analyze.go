@@ -0,0 +1,476 @@
+package main
+
+//
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sspencer/colorart"
+	"github.com/sspencer/colorart/album"
+	"github.com/sspencer/colorart/anim"
+	"github.com/sspencer/colorart/loader"
+	"github.com/sspencer/colorart/video"
+)
+
+// smallest color difference (delta-E) most people notice
+const justNoticeable = 2.3
+
+// analyzer holds the flags of the analyze command.
+type analyzer struct {
+	inputFlags
+	imageFlags
+	outputFlags
+	cacheFlags
+	watchFlags
+
+	frames  string
+	video   bool
+	rawSize string
+	fps     float64
+	every   int
+	scene   float64
+	albums  bool
+	names   string
+	minSize int
+
+	// out writes each result in the -format output format.
+	out resultWriter
+}
+
+func runAnalyze(args []string) error {
+	a := &analyzer{}
+	fs := newFlagSet("analyze", "<file, directory, glob or - for a list on stdin> ...",
+		"Analyze prints the background, primary, secondary and detail colors of images,\n"+
+			"audio files, e-books and comics, animations, videos and album folders.\n"+
+			"Directories are searched recursively, and files that fail are reported\n"+
+			"without stopping the others.  With -video or -albums, each argument is a\n"+
+			"video (- for stdin) or music library.")
+	a.inputFlags.register(fs)
+	a.imageFlags.register(fs, 500)
+	a.outputFlags.register(fs)
+	a.cacheFlags.register(fs)
+	a.watchFlags.register(fs)
+	fs.StringVar(&a.frames, "frames", "first", "frames of animated GIF/PNG files to analyze: first, all (weighted by delay) or each")
+	fs.BoolVar(&a.video, "video", false, "inputs are y4m video streams (- for stdin) analyzed into a color track")
+	fs.StringVar(&a.rawSize, "raw", "", "with -video, inputs are headerless rgb24 frames of this size (WxH)")
+	fs.Float64Var(&a.fps, "fps", 25, "frame rate of -raw video")
+	fs.IntVar(&a.every, "every", 1, "with -video, analyze every Nth frame")
+	fs.Float64Var(&a.scene, "scene", 30, "with -video, background color difference (delta-E) that starts a new scene; 0 disables")
+	fs.BoolVar(&a.albums, "albums", false, "inputs are music library folders; analyze the cover of each album folder")
+	fs.StringVar(&a.names, "cover-names", strings.Join(album.DefaultNames, ","), "with -albums, cover file name patterns in order of preference")
+	fs.IntVar(&a.minSize, "min-size", 0, "with -albums, skip cover images smaller than this many pixels wide or high")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	if a.frames != "first" && a.frames != "all" && a.frames != "each" {
+		return fmt.Errorf("unknown -frames %q", a.frames)
+	}
+
+	if a.watch {
+		if a.video || a.albums {
+			return errors.New("-watch doesn't work with -video or -albums")
+		}
+		return a.watchCovers(fs.Args())
+	}
+
+	w, err := a.create()
+	if err != nil {
+		return err
+	}
+
+	defer w.Close()
+
+	a.out, err = a.newWriter(w, columns{video: a.video, timing: a.timing}, a.load)
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	if a.video || a.albums {
+		// videos and libraries are read as they are, one at a time
+		for _, fn := range fs.Args() {
+			if err := a.analyzeStream(fn); err != nil {
+				// albums that failed have been logged already
+				if err != errFailed {
+					log.Printf("%s: %s", fn, err)
+				}
+				failed = true
+			}
+		}
+	} else {
+		err := a.processArgs(fs.Args(), func(fn string) (interface{}, error) {
+			return a.analyzeCovers(fn)
+		}, func(_ string, v interface{}) error {
+			for _, cover := range v.([]*Cover) {
+				if err := a.out.Write(cover); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil && err != errFailed {
+			return err
+		}
+		failed = err == errFailed
+	}
+
+	if err := a.out.Flush(); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if failed {
+		return errFailed
+	}
+	return nil
+}
+
+// watchCovers analyzes the files named by args again whenever they change.
+// Text, ndjson, csv and tsv results of the changed files are written to
+// stdout as they come.  With -format json, or with -o, every result is
+// written again instead, replacing the -o file.
+func (a *analyzer) watchCovers(args []string) error {
+	snapshot := a.format == "json" || a.output != "" && a.output != "-"
+	cols := columns{timing: a.timing}
+
+	var err error
+	a.out, err = a.newWriter(os.Stdout, cols, a.load)
+	if err != nil {
+		return err
+	}
+
+	results := make(map[string][]*Cover)
+	return a.watchArgs(args, func(changed, removed []string) error {
+		for _, fn := range removed {
+			delete(results, fn)
+			if !snapshot {
+				log.Printf("%s: removed", fn)
+			}
+		}
+
+		// files that fail, perhaps because they are still being
+		// written, keep their previous results
+		err := a.process(changed, func(fn string) (interface{}, error) {
+			return a.analyzeCovers(fn)
+		}, func(fn string, v interface{}) error {
+			covers := v.([]*Cover)
+			results[fn] = covers
+
+			if snapshot {
+				return nil
+			}
+			for _, cover := range covers {
+				if err := a.out.Write(cover); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil && err != errFailed {
+			return err
+		}
+
+		if !snapshot {
+			return a.out.Flush()
+		}
+
+		var buf bytes.Buffer
+		out, _ := newResultWriter(&buf, a.format, cols)
+		files := make([]string, 0, len(results))
+		for fn := range results {
+			files = append(files, fn)
+		}
+		sort.Strings(files)
+		for _, fn := range files {
+			for _, cover := range results[fn] {
+				if err := out.Write(cover); err != nil {
+					return err
+				}
+			}
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+
+		if a.output == "" || a.output == "-" {
+			_, err := os.Stdout.Write(buf.Bytes())
+			return err
+		}
+		return writeFileAtomic(a.output, buf.Bytes())
+	})
+}
+
+// analyzeStream writes the results for a video or a music library.
+func (a *analyzer) analyzeStream(fn string) error {
+	if a.albums {
+		return a.analyzeAlbums(fn)
+	}
+	return a.analyzeVideo(fn)
+}
+
+// analyzeCovers analyzes an image, or the frames of an animation.
+func (a *analyzer) analyzeCovers(fn string) ([]*Cover, error) {
+	if a.frames == "first" {
+		cover, err := a.analyzeStill(fn)
+		if err != nil {
+			return nil, err
+		}
+		return []*Cover{cover}, nil
+	}
+
+	key := fmt.Sprintf("analyze size=%d frames=%s", a.size, a.frames)
+	return a.cached(fn, key, func() ([]*Cover, error) {
+		return a.analyzeFrames(fn, a.frames == "each")
+	})
+}
+
+// analyzeStill analyzes an image, or the first frame of an animation.
+func (a *analyzer) analyzeStill(fn string) (*Cover, error) {
+	key := fmt.Sprintf("analyze size=%d frames=first", a.size)
+	covers, err := a.cached(fn, key, func() ([]*Cover, error) {
+		cover, err := a.analyzeFile(fn)
+		if err != nil {
+			return nil, err
+		}
+		return []*Cover{cover}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return covers[0], nil
+}
+
+func newCover(filename string, r colorart.Result) *Cover {
+	return &Cover{
+		Filename:        filename,
+		BackgroundColor: r.Background.String(),
+		PrimaryColor:    r.Primary.String(),
+		SecondaryColor:  r.Secondary.String(),
+		DetailColor:     r.Detail.String(),
+	}
+}
+
+// logTiming prints how long a step took to stderr with -timing.
+func (f *outputFlags) logTiming(step, filename string, d time.Duration) {
+	if f.timing {
+		fmt.Fprintf(os.Stderr, "- %s %s took %s\n", step, path.Base(filename), d)
+	}
+}
+
+// withTiming adds the step timings to c with -timing.
+func (f *outputFlags) withTiming(c *Cover, resize, analyze time.Duration) *Cover {
+	if f.timing {
+		c.Timing = &Timing{Resize: milliseconds(resize), Analyze: milliseconds(analyze)}
+	}
+	return c
+}
+
+// withImage keeps img with c for -preview thumbnails.  Otherwise it isn't
+// kept, as results may be held until the end of the run.
+func (f *outputFlags) withImage(c *Cover, img image.Image) *Cover {
+	if f.preview && f.thumbnail > 0 {
+		c.image = img
+	}
+	return c
+}
+
+func (a *analyzer) analyzeFile(filename string) (*Cover, error) {
+	img, err := a.load(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	img = a.shrink(img)
+	resized := time.Since(start)
+	a.logTiming("RESIZE", filename, resized)
+
+	start = time.Now()
+	r := colorart.AnalyzeResult(img, a.options())
+	analyzed := time.Since(start)
+	a.logTiming("ANALYZE", filename, analyzed)
+
+	cover := a.withImage(newCover(filename, r), img)
+	return a.withTiming(cover, resized, analyzed), nil
+}
+
+// decodeFrames decodes the frames of an animation.  Every frame is drawn on
+// a canvas the size of the image, so the size of the canvas, and then of
+// every frame together, is checked against the pixel budget first.
+func (a *analyzer) decodeFrames(filename string) ([]colorart.Frame, error) {
+	ext := strings.ToLower(path.Ext(filename))
+	if ext != ".gif" && ext != ".png" && ext != ".apng" {
+		img, err := a.load(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		return []colorart.Frame{{Image: img}}, nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	if _, _, err := loader.DecodeConfig(file, a.maxPixels); err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	if ext == ".gif" {
+		return anim.DecodeGIFLimit(file, a.maxPixels)
+	}
+	return anim.DecodeAPNGLimit(file, a.maxPixels)
+}
+
+// analyzeFrames analyzes all frames of an animation, combined or one by one.
+// Small frames are not resized, so paletted frames keep their fast path.
+func (a *analyzer) analyzeFrames(filename string, each bool) ([]*Cover, error) {
+	frames, err := a.decodeFrames(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	for i, f := range frames {
+		frames[i].Image = a.shrink(f.Image)
+	}
+	resized := time.Since(start)
+	a.logTiming("RESIZE", filename, resized)
+
+	start = time.Now()
+	var results []colorart.Result
+	if each {
+		results = colorart.AnalyzeEachFrame(frames, a.options())
+	} else {
+		results = []colorart.Result{colorart.AnalyzeFrames(frames, a.options())}
+	}
+	analyzed := time.Since(start)
+	a.logTiming("ANALYZE", filename, analyzed)
+
+	var covers []*Cover
+	for i, r := range results {
+		name := filename
+		if each {
+			name = fmt.Sprintf("%s#%d", filename, i)
+		}
+		cover := a.withImage(newCover(name, r), frames[i].Image)
+		covers = append(covers, a.withTiming(cover, resized, analyzed))
+	}
+
+	return covers, nil
+}
+
+// analyzeVideo writes a time coded color track for a video stream.
+func (a *analyzer) analyzeVideo(filename string) error {
+	var r io.Reader = os.Stdin
+	if filename != "-" {
+		file, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+		r = file
+	}
+
+	var fr video.FrameReader
+	var err error
+	if a.rawSize != "" {
+		var w, h int
+		if _, err := fmt.Sscanf(a.rawSize, "%dx%d", &w, &h); err != nil {
+			return fmt.Errorf("bad -raw size %q", a.rawSize)
+		}
+		fr, err = video.NewRawReaderLimit(r, w, h, a.fps, a.maxPixels)
+	} else {
+		fr, err = video.NewY4MReaderLimit(r, a.maxPixels)
+	}
+	if err != nil {
+		return err
+	}
+
+	opts := &video.TrackOptions{
+		Every:          a.every,
+		Hysteresis:     justNoticeable,
+		SceneThreshold: a.scene,
+		Options:        a.options(),
+	}
+	return video.StreamTrack(fr, opts, func(s video.Sample) error {
+		cover := newCover(filename, s.Result)
+		cover.Time = formatTime(s.Time)
+		cover.Scene = s.SceneChange
+		return a.out.Write(cover)
+	})
+}
+
+// analyzeAlbums writes one result per album folder found under root.  It
+// returns errFailed once every album is done if any of them failed.
+func (a *analyzer) analyzeAlbums(root string) error {
+	rules := &album.Rules{
+		Names:        strings.Split(a.names, ","),
+		MinSize:      a.minSize,
+		PreferLarger: true,
+		Embedded:     true,
+	}
+
+	list, err := album.Scan(root, rules)
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	for _, al := range list {
+		if al.Err != nil {
+			log.Printf("%s: %s", al.Dir, al.Err)
+			failed = true
+			continue
+		}
+		if al.Cover == "" {
+			log.Printf("%s: no cover found", al.Dir)
+			continue
+		}
+
+		cover, err := a.analyzeStill(al.Cover)
+		if err != nil {
+			log.Printf("%s: %s", al.Dir, err)
+			failed = true
+			continue
+		}
+
+		cover.Filename = al.Dir
+		if err := a.out.Write(cover); err != nil {
+			return err
+		}
+	}
+
+	if failed {
+		return errFailed
+	}
+	return nil
+}
+
+// formatTime formats d as hh:mm:ss.mmm
+func formatTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
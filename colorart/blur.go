@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+
+	"github.com/disintegration/gift"
+)
+
+// blurrer holds the flags of the blur command.
+type blurrer struct {
+	inputFlags
+	imageFlags
+
+	sigma float64
+	dir   string
+}
+
+func runBlur(args []string) error {
+	b := &blurrer{}
+	fs := newFlagSet("blur", "<file, directory, glob or -> ...",
+		"Blur writes a blurred JPEG of each cover, like the backgrounds of iTunes 12,\n"+
+			"named after the file with .blur.jpg in place of its extension.  Files with\n"+
+			"the same name in different folders get the folder name too.")
+	b.inputFlags.register(fs)
+	b.imageFlags.register(fs, 320)
+	fs.Float64Var(&b.sigma, "sigma", 40, "gaussian blur radius")
+	fs.StringVar(&b.dir, "dir", ".", "directory to write the blurred files to")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	files, failed := expandArgs(fs.Args(), os.Stdin)
+	names := outputNames(files)
+	err := b.process(files, func(fn string) (interface{}, error) {
+		return b.blur(fn, names[fn])
+	}, func(_ string, v interface{}) error {
+		fmt.Println("Wrote file:", v)
+		return nil
+	})
+	if err == nil && failed {
+		return errFailed
+	}
+	return err
+}
+
+// blur writes the blurred fn, named name.blur.jpg, to -dir.
+func (b *blurrer) blur(fn, name string) (string, error) {
+	img, err := b.load(fn)
+	if err != nil {
+		return "", err
+	}
+
+	img = b.shrink(img)
+
+	g := gift.New(gift.GaussianBlur(float32(b.sigma)))
+	dst := image.NewRGBA(g.Bounds(img.Bounds()))
+	g.Draw(dst, img)
+
+	out := filepath.Join(b.dir, name+".blur.jpg")
+
+	w, err := os.Create(out)
+	if err != nil {
+		return "", err
+	}
+
+	if err := jpeg.Encode(w, dst, nil); err != nil {
+		w.Close()
+		return "", err
+	}
+
+	return out, w.Close()
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBlurNames(t *testing.T) {
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "x/cover.png"), color.NRGBA{200, 30, 30, 255})
+	writePNG(t, filepath.Join(dir, "y/cover.png"), color.NRGBA{20, 30, 200, 255})
+	out := filepath.Join(dir, "out")
+	if err := os.Mkdir(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runBlur([]string{"-dir", out, "-sigma", "1", filepath.Join(dir, "x"), filepath.Join(dir, "y")}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"x-cover.blur.jpg", "y-cover.blur.jpg"} {
+		if _, err := os.Stat(filepath.Join(out, name)); err != nil {
+			t.Errorf("covers with the same name should each be blurred: %v", err)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cacheVersion is part of every cache key.  Bump it when the analysis
+// changes so old results are no longer used.
+const cacheVersion = 1
+
+// defaultCacheDir returns the directory results are cached in unless
+// -cache-dir says otherwise, or "" if there is no user cache directory.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "colorart")
+}
+
+// cacheFlags are the flags of the commands that cache their results.
+type cacheFlags struct {
+	cacheDir string
+	noCache  bool
+}
+
+func (f *cacheFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.cacheDir, "cache-dir", defaultCacheDir(), "directory results are cached in, keyed by file content and options")
+	fs.BoolVar(&f.noCache, "no-cache", false, "analyze every file, without reading or writing the cache")
+}
+
+// cached returns the covers of filename analyzed with the options in key.
+// If the same content has been analyzed with the same options before, the
+// covers come from the cache, otherwise from analyze and are cached.
+// Cached covers have no timing.
+func (f *cacheFlags) cached(filename, key string, analyze func() ([]*Cover, error)) ([]*Cover, error) {
+	if f.noCache || f.cacheDir == "" {
+		return analyze()
+	}
+
+	path, err := f.cachePath(filename, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if covers, ok := readCache(path, filename); ok {
+		return covers, nil
+	}
+
+	covers, err := analyze()
+	if err != nil {
+		return nil, err
+	}
+
+	// a cache that can't be written only makes the next run slower
+	writeCache(path, filename, covers)
+	return covers, nil
+}
+
+// cachePath returns the cache file for the content of filename and key.
+func (f *cacheFlags) cachePath(filename, key string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00", cacheVersion, key)
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	return filepath.Join(f.cacheDir, sum[:2], sum+".json"), nil
+}
+
+// readCache reads the covers cached in path, renamed for filename.  Reading
+// a cache file marks it as used for pruning.
+func readCache(path, filename string) ([]*Cover, bool) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var covers []*Cover
+	if err := json.Unmarshal(data, &covers); err != nil {
+		return nil, false
+	}
+
+	for _, c := range covers {
+		c.Filename = filename + c.Filename
+	}
+
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return covers, true
+}
+
+// writeCache caches covers in path.  Cover names are stored without
+// filename, so the same content can be found under another name.
+func writeCache(path, filename string, covers []*Cover) error {
+	stored := make([]Cover, len(covers))
+	for i, c := range covers {
+		stored[i] = *c
+		stored[i].Filename = strings.TrimPrefix(c.Filename, filename)
+		stored[i].Timing = nil
+	}
+
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data)
+}
+
+func runCache(args []string) error {
+	var dir string
+	var olderThan time.Duration
+	var maxSize int64
+	fs := newFlagSet("cache", "prune|clear",
+		"Cache prunes the result cache: prune removes results unused for -older-than\n"+
+			"and then the least recently used until the cache fits in -max-size, and\n"+
+			"clear removes every result.")
+	fs.StringVar(&dir, "cache-dir", defaultCacheDir(), "directory results are cached in")
+	fs.DurationVar(&olderThan, "older-than", 30*24*time.Hour, "with prune, remove results unused for this long; 0 keeps them")
+	fs.Int64Var(&maxSize, "max-size", 0, "with prune, remove the least recently used results until the cache is at most this many bytes; 0 is unlimited")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	if dir == "" {
+		return fmt.Errorf("no cache directory")
+	}
+
+	switch fs.Arg(0) {
+	case "prune":
+		n, err := pruneCache(dir, olderThan, maxSize)
+		fmt.Printf("Removed %d cached results\n", n)
+		return err
+	case "clear":
+		n, err := pruneCache(dir, -1, 0)
+		fmt.Printf("Removed %d cached results\n", n)
+		return err
+	}
+
+	fs.Usage()
+	return errUsage
+}
+
+// isHex reports whether s is made of lowercase hex digits only.
+func isHex(s string) bool {
+	for _, r := range s {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// listCache returns the results in dir, laid out as cachePath writes
+// them: <2 hex>/<64 hex>.json.  Any other file or directory is left alone.
+func listCache(dir string) (files []os.FileInfo, paths []string, err error) {
+	subdirs, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	for _, d := range subdirs {
+		if !d.IsDir() || len(d.Name()) != 2 || !isHex(d.Name()) {
+			continue
+		}
+
+		entries, err := ioutil.ReadDir(filepath.Join(dir, d.Name()))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, e := range entries {
+			sum := strings.TrimSuffix(e.Name(), ".json")
+			if !e.Mode().IsRegular() || !strings.HasSuffix(e.Name(), ".json") ||
+				len(sum) != 2*sha256.Size || !isHex(sum) || sum[:2] != d.Name() {
+				continue
+			}
+			files = append(files, e)
+			paths = append(paths, filepath.Join(dir, d.Name(), e.Name()))
+		}
+	}
+	return files, paths, nil
+}
+
+// pruneCache removes the results in dir unused for olderThan, then the least
+// recently used until the rest fit in maxSize bytes.  A negative olderThan
+// removes everything and 0 keeps everything, as does a maxSize of 0.  Only
+// files laid out as cachePath writes them are results.  It returns the
+// number of results removed.
+func pruneCache(dir string, olderThan time.Duration, maxSize int64) (int, error) {
+	files, paths, err := listCache(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	// most recently used first
+	order := make([]int, len(files))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return files[order[i]].ModTime().After(files[order[j]].ModTime())
+	})
+
+	removed := 0
+	var size int64
+	full := false
+	for _, i := range order {
+		remove := olderThan < 0 || olderThan > 0 && time.Since(files[i].ModTime()) > olderThan
+		if !remove && maxSize > 0 && (full || size+files[i].Size() > maxSize) {
+			remove, full = true, true
+		}
+
+		if !remove {
+			size += files[i].Size()
+			continue
+		}
+
+		if err := os.Remove(paths[i]); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writePNG(t *testing.T, filename string, c color.Color) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, c)
+		}
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// cacheFiles returns the number of results cached in dir.
+func cacheFiles(dir string) int {
+	files, _, _ := listCache(dir)
+	return len(files)
+}
+
+// resultName returns the name cachePath gives the result with a sum made
+// of prefix and then digit.
+func resultName(prefix, digit string) string {
+	sum := prefix + strings.Repeat(digit, 62)
+	return filepath.Join(sum[:2], sum+".json")
+}
+
+func TestCached(t *testing.T) {
+	dir := t.TempDir()
+	f := &cacheFlags{cacheDir: filepath.Join(dir, "cache")}
+	a := filepath.Join(dir, "a.png")
+	writePNG(t, a, color.NRGBA{200, 30, 30, 255})
+
+	calls := 0
+	analyze := func(filename string) func() ([]*Cover, error) {
+		return func() ([]*Cover, error) {
+			calls++
+			return []*Cover{
+				{Filename: filename + "#0", BackgroundColor: "#c81e1e", Timing: &Timing{1, 2}},
+				{Filename: filename + "#1", BackgroundColor: "#000000"},
+			}, nil
+		}
+	}
+
+	if _, err := f.cached(a, "key", analyze(a)); err != nil {
+		t.Fatal(err)
+	}
+	covers, err := f.cached(a, "key", analyze(a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("second run should come from the cache, analyzed %d times", calls)
+	}
+	if covers[0].Timing != nil {
+		t.Error("cached covers should have no timing")
+	}
+
+	// the same content under another name
+	b := filepath.Join(dir, "renamed.png")
+	if err := os.Rename(a, b); err != nil {
+		t.Fatal(err)
+	}
+	covers, err = f.cached(b, "key", analyze(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Error("a renamed file should come from the cache")
+	}
+	if len(covers) != 2 || covers[0].Filename != b+"#0" || covers[1].Filename != b+"#1" || covers[0].BackgroundColor != "#c81e1e" {
+		t.Errorf("cached covers should be named after %s: %+v %+v", b, covers[0], covers[1])
+	}
+
+	// other options, or other content
+	f.cached(b, "other key", analyze(b))
+	writePNG(t, b, color.NRGBA{20, 30, 200, 255})
+	f.cached(b, "key", analyze(b))
+	if calls != 3 {
+		t.Errorf("new options and new content should both be analyzed, analyzed %d times", calls)
+	}
+
+	f.noCache = true
+	f.cached(b, "key", analyze(b))
+	if calls != 4 {
+		t.Error("-no-cache should always analyze")
+	}
+	if n := cacheFiles(f.cacheDir); n != 3 {
+		t.Errorf("cache should hold 3 results, not %d", n)
+	}
+}
+
+func TestCacheKeys(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "a.png")
+	writePNG(t, fn, color.NRGBA{200, 30, 30, 255})
+
+	a := &analyzer{frames: "first"}
+	a.cacheDir = filepath.Join(dir, "cache")
+	a.maxPixels = 1 << 20
+
+	run := func(size int, frames string) {
+		a.size, a.frames = size, frames
+		if _, err := a.analyzeCovers(fn); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run(100, "first")
+	run(100, "first")
+	if n := cacheFiles(a.cacheDir); n != 1 {
+		t.Fatalf("the same options should share a result, got %d", n)
+	}
+
+	run(200, "first")
+	run(100, "all")
+	run(100, "each")
+	if n := cacheFiles(a.cacheDir); n != 4 {
+		t.Errorf("each -size and -frames should have its own result, got %d", n)
+	}
+}
+
+func TestPruneCache(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	results := []struct {
+		name string
+		size int
+		age  time.Duration
+	}{
+		{resultName("aa", "1"), 100, time.Hour},
+		{resultName("bb", "2"), 100, 2 * time.Hour},
+		{resultName("bb", "3"), 100, 3 * time.Hour},
+		{resultName("cc", "4"), 100, 100 * time.Hour},
+	}
+	create := func() {
+		for _, r := range results {
+			path := filepath.Join(dir, r.name)
+			os.MkdirAll(filepath.Dir(path), 0755)
+			data := append([]byte("[]"), bytes.Repeat([]byte(" "), r.size-2)...)
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			os.Chtimes(path, now.Add(-r.age), now.Add(-r.age))
+		}
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+
+	create()
+	if n, err := pruneCache(dir, 50*time.Hour, 0); err != nil || n != 1 || exists(results[3].name) {
+		t.Errorf("-older-than should remove the old result: removed %d, %v", n, err)
+	}
+
+	// the least recently used go first, however they are named
+	create()
+	if n, err := pruneCache(dir, 0, 250); err != nil || n != 2 || !exists(results[0].name) || !exists(results[1].name) {
+		t.Errorf("-max-size should keep the two most recent: removed %d, %v", n, err)
+	}
+
+	// reading a result marks it as used
+	create()
+	readCache(filepath.Join(dir, results[3].name), "x")
+	if n, _ := pruneCache(dir, 0, 100); n != 3 || !exists(results[3].name) {
+		t.Errorf("a result just read should be kept: removed %d", n)
+	}
+
+	// files the cache never wrote, even .json ones
+	strays := []string{
+		"notes.txt",
+		"package.json",
+		"sub/tsconfig.json",
+		"aa/notes.json",
+		resultName("dd", "x"),
+		filepath.Join("ee", filepath.Base(resultName("ff", "5"))),
+		filepath.Join("sub", resultName("aa", "6")),
+	}
+	create()
+	for _, name := range strays {
+		touch(t, filepath.Join(dir, name))
+	}
+	if n, err := pruneCache(dir, -1, 0); err != nil || n != len(results) || cacheFiles(dir) != 0 {
+		t.Errorf("clear should remove every result: removed %d, %v", n, err)
+	}
+	for _, name := range strays {
+		if !exists(name) {
+			t.Errorf("clear should leave %s alone", name)
+		}
+	}
+
+	if n, err := pruneCache(filepath.Join(dir, "missing"), -1, 0); err != nil || n != 0 {
+		t.Errorf("a missing cache is empty: removed %d, %v", n, err)
+	}
+}
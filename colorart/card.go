@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sspencer/colorart"
+	"github.com/sspencer/colorart/card"
+)
+
+// carder holds the flags of the card command.
+type carder struct {
+	inputFlags
+	imageFlags
+
+	height                  int
+	blur                    float64
+	title, subtitle, detail string
+	dir                     string
+}
+
+func runCard(args []string) error {
+	c := &carder{}
+	fs := newFlagSet("card", "<file, directory, glob or -> ...",
+		"Card writes a PNG card for each cover: the cover beside a panel in its\n"+
+			"background color with sample text in its primary, secondary and detail\n"+
+			"colors, named after the file with .card.png in place of its extension.\n"+
+			"Files with the same name in different folders get the folder name too.")
+	c.inputFlags.register(fs)
+	c.imageFlags.register(fs, 500)
+	fs.IntVar(&c.height, "height", card.DefaultSize, "height of the card; it is twice as wide")
+	fs.Float64Var(&c.blur, "blur", 0, "gaussian blur radius of the cover; 0 leaves it sharp")
+	fs.StringVar(&c.title, "title", "", "title drawn in the primary color (default the file name)")
+	fs.StringVar(&c.subtitle, "subtitle", "Artist", "text drawn in the secondary color")
+	fs.StringVar(&c.detail, "detail", "", "text drawn in the detail color (default the color codes)")
+	fs.StringVar(&c.dir, "dir", ".", "directory to write the cards to")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	files, failed := expandArgs(fs.Args(), os.Stdin)
+	names := outputNames(files)
+	err := c.process(files, func(fn string) (interface{}, error) {
+		return c.card(fn, names[fn])
+	}, func(_ string, v interface{}) error {
+		fmt.Println("Wrote file:", v)
+		return nil
+	})
+	if err == nil && failed {
+		return errFailed
+	}
+	return err
+}
+
+// card writes the card of fn, named name.card.png, to -dir.
+func (c *carder) card(fn, name string) (string, error) {
+	img, err := c.load(fn)
+	if err != nil {
+		return "", err
+	}
+
+	r := colorart.AnalyzeResult(c.shrink(img), c.options())
+
+	opts := &card.Options{
+		Size:     c.height,
+		Blur:     c.blur,
+		Title:    c.title,
+		Subtitle: c.subtitle,
+		Detail:   c.detail,
+	}
+	if opts.Title == "" {
+		base := filepath.Base(fn)
+		opts.Title = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	if opts.Detail == "" {
+		opts.Detail = fmt.Sprintf("%s %s %s", r.Primary, r.Secondary, r.Detail)
+	}
+
+	out := filepath.Join(c.dir, name+".card.png")
+	w, err := os.Create(out)
+	if err != nil {
+		return "", err
+	}
+
+	if err := card.Encode(w, img, r, opts); err != nil {
+		w.Close()
+		return "", err
+	}
+
+	return out, w.Close()
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSkipGenerated(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.png", "a.card.png", "a.blur.jpg", "d/b.jpg", "d/b.CARD.png"} {
+		touch(t, filepath.Join(dir, name))
+	}
+
+	files, _ := expandArgs([]string{dir}, nil)
+	want := []string{filepath.Join(dir, "a.png"), filepath.Join(dir, "d/b.jpg")}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("walk should skip generated files: got %q, want %q", files, want)
+	}
+
+	files, _ = expandArgs([]string{filepath.Join(dir, "*.png")}, nil)
+	if want := []string{filepath.Join(dir, "a.png")}; !reflect.DeepEqual(files, want) {
+		t.Errorf("glob should skip generated files: got %q, want %q", files, want)
+	}
+
+	files, _ = expandArgs([]string{filepath.Join(dir, "*.card.png"), filepath.Join(dir, "a.blur.jpg")}, nil)
+	if want := []string{filepath.Join(dir, "a.card.png"), filepath.Join(dir, "a.blur.jpg")}; !reflect.DeepEqual(files, want) {
+		t.Errorf("generated files asked for should be kept: got %q, want %q", files, want)
+	}
+}
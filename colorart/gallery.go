@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"image/jpeg"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sspencer/colorart"
+	"github.com/sspencer/colorart/loader"
+)
+
+// defaultTemplate is the page used unless -template names another.
+//
+//go:embed covers.html
+var defaultTemplate string
+
+// galleryCount is the number of palette colors given to templates.
+const galleryCount = 5
+
+// cover is what templates see of each cover.
+type cover struct {
+	Filename, BackgroundColor, PrimaryColor, SecondaryColor, DetailColor string
+
+	// Image is the src of the cover: the file itself, or the cover
+	// extracted from an audio file, e-book or comic as a data URL.
+	Image template.URL
+
+	// Name is the base name of Filename, without its extension.
+	Name string
+
+	// Palette is the most common colors of the cover.
+	Palette []Swatch
+
+	// PrimaryContrast, SecondaryContrast and DetailContrast are the WCAG
+	// contrast ratios of the text colors against the background.
+	PrimaryContrast, SecondaryContrast, DetailContrast float64
+
+	// PrimaryFallback, SecondaryFallback and DetailFallback are set when
+	// no color of the cover stood out from the background, so the text
+	// color is black or white.
+	PrimaryFallback, SecondaryFallback, DetailFallback bool
+}
+
+// galleryer holds the flags of the gallery command.
+type galleryer struct {
+	inputFlags
+	imageFlags
+	watchFlags
+
+	template string
+	output   string
+}
+
+func runGallery(args []string) error {
+	g := &galleryer{}
+	fs := newFlagSet("gallery", "<file, directory, glob or -> ...",
+		"Gallery writes an HTML page showing each cover on its background color, with\n"+
+			"text in its primary, secondary and detail colors.  -template replaces the\n"+
+			"built-in page.  With -watch, the page is written to -o again whenever\n"+
+			"covers are added, change or are removed.")
+	g.inputFlags.register(fs)
+	g.imageFlags.register(fs, 200)
+	g.watchFlags.register(fs)
+	fs.StringVar(&g.template, "template", "", "HTML template executed with the list of covers, instead of the built-in one")
+	fs.StringVar(&g.output, "o", "", "write the page to this file instead of stdout")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	if g.watch && g.output == "" {
+		return errors.New("-watch needs -o")
+	}
+
+	tpl := defaultTemplate
+	if g.template != "" {
+		b, err := ioutil.ReadFile(g.template)
+		if err != nil {
+			return err
+		}
+		tpl = string(b)
+	}
+
+	t, err := template.New("webpage").Parse(tpl)
+	if err != nil {
+		return err
+	}
+
+	if g.watch {
+		return g.watchGallery(t, fs.Args())
+	}
+
+	var covers []cover
+	err = g.processArgs(fs.Args(), func(fn string) (interface{}, error) {
+		return g.analyzeFile(fn)
+	}, func(_ string, v interface{}) error {
+		covers = append(covers, v.(cover))
+		return nil
+	})
+	if err != nil && err != errFailed {
+		return err
+	}
+	failed := err == errFailed
+
+	if g.output == "" || g.output == "-" {
+		err = t.Execute(os.Stdout, covers)
+	} else {
+		err = g.writePage(t, covers)
+	}
+	if err != nil {
+		return err
+	}
+	if failed {
+		return errFailed
+	}
+	return nil
+}
+
+// watchGallery writes the page again whenever covers are added, change or
+// are removed, analyzing only the covers that changed.
+func (g *galleryer) watchGallery(t *template.Template, args []string) error {
+	covers := make(map[string]cover)
+	return g.watchArgs(args, func(changed, removed []string) error {
+		for _, fn := range removed {
+			delete(covers, fn)
+		}
+
+		// covers that fail, perhaps because they are still being
+		// written, keep their previous colors
+		err := g.process(changed, func(fn string) (interface{}, error) {
+			return g.analyzeFile(fn)
+		}, func(fn string, v interface{}) error {
+			covers[fn] = v.(cover)
+			return nil
+		})
+		if err != nil && err != errFailed {
+			return err
+		}
+
+		files := make([]string, 0, len(covers))
+		for fn := range covers {
+			files = append(files, fn)
+		}
+		sort.Strings(files)
+
+		list := make([]cover, len(files))
+		for i, fn := range files {
+			list[i] = covers[fn]
+		}
+
+		if err := g.writePage(t, list); err != nil {
+			return err
+		}
+		log.Printf("wrote %s with %d covers", g.output, len(list))
+		return nil
+	})
+}
+
+// writePage replaces the -o file with the page for covers.
+func (g *galleryer) writePage(t *template.Template, covers []cover) error {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, covers); err != nil {
+		return err
+	}
+	return writeFileAtomic(g.output, buf.Bytes())
+}
+
+// fileURL returns the file: URL of filename, which may be relative.
+func fileURL(filename string) (template.URL, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return "", err
+	}
+	return template.URL((&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()), nil
+}
+
+func (g *galleryer) analyzeFile(filename string) (cover, error) {
+	img, err := g.load(filename)
+	if err != nil {
+		return cover{}, err
+	}
+
+	img = g.shrink(img)
+
+	opts := g.options()
+	opts.KeepHistograms = true
+	r := colorart.AnalyzeResult(img, opts)
+
+	link, err := fileURL(filename)
+	if err != nil {
+		return cover{}, err
+	}
+
+	c := cover{
+		Filename:          filename,
+		BackgroundColor:   r.Background.String(),
+		PrimaryColor:      r.Primary.String(),
+		SecondaryColor:    r.Secondary.String(),
+		DetailColor:       r.Detail.String(),
+		Image:             link,
+		Name:              strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)),
+		Palette:           palette(r.Histogram, galleryCount),
+		PrimaryContrast:   r.Primary.ContrastRatio(r.Background),
+		SecondaryContrast: r.Secondary.ContrastRatio(r.Background),
+		DetailContrast:    r.Detail.ContrastRatio(r.Background),
+		PrimaryFallback:   r.PrimaryFallback,
+		SecondaryFallback: r.SecondaryFallback,
+		DetailFallback:    r.DetailFallback,
+	}
+
+	if loader.IsContainer(filename) {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, nil); err != nil {
+			return cover{}, err
+		}
+		c.Image = template.URL("data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()))
+	}
+
+	return c, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileURL(t *testing.T) {
+	name := filepath.Join("my art", "a #1?.png")
+	link, err := fileURL(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(string(link), "file:///") || !strings.HasSuffix(string(link), "/my%20art/a%20%231%3F.png") {
+		t.Errorf("%s should be an absolute, escaped file URL", link)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(string(link))
+	if err != nil || u.Scheme != "file" || u.Path != filepath.ToSlash(filepath.Join(wd, name)) {
+		t.Errorf("%s should link to %s in %s: %v", link, name, wd, err)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sspencer/colorart/loader"
+)
+
+// errFailed is returned when some of the inputs could not be processed.
+// Each error has already been logged.
+var errFailed = errors.New("some inputs failed")
+
+// inputFlags are the flags shared by the commands that read files.
+type inputFlags struct {
+	jobs int
+}
+
+func (f *inputFlags) register(fs *flag.FlagSet) {
+	fs.IntVar(&f.jobs, "jobs", 1, "number of files processed at the same time")
+}
+
+// generatedSuffixes end the names of the files the card and blur commands
+// write.
+var generatedSuffixes = []string{".card.png", ".blur.jpg"}
+
+// isGenerated reports whether name looks like a file colorart wrote.
+func isGenerated(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range generatedSuffixes {
+		if strings.HasSuffix(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandArgs turns arguments into the files to process.  Directories are
+// walked for the files Load supports, skipping the cards and blurred
+// images colorart writes, glob patterns are expanded, and -
+// reads one argument per line from stdin.  A file named more than once is
+// only processed the first time.  Arguments, matches and directories that
+// can't be read are logged and skipped, and failed is set.
+func expandArgs(args []string, stdin io.Reader) (files []string, failed bool) {
+	report := func(path string, err error) {
+		log.Printf("%s: %s", path, err)
+		failed = true
+	}
+
+	seen := make(map[string]bool)
+	add := func(expanded []string) {
+		for _, fn := range expanded {
+			if key := filepath.Clean(fn); !seen[key] {
+				seen[key] = true
+				files = append(files, fn)
+			}
+		}
+	}
+
+	for _, arg := range args {
+		if arg != "-" {
+			add(expandArg(arg, report))
+			continue
+		}
+
+		s := bufio.NewScanner(stdin)
+		for s.Scan() {
+			line := strings.TrimSpace(s.Text())
+			if line == "" {
+				continue
+			}
+
+			add(expandArg(line, report))
+		}
+		if err := s.Err(); err != nil {
+			log.Printf("stdin: %s", err)
+			failed = true
+		}
+	}
+
+	return files, failed
+}
+
+// expandArg expands a single argument that isn't -.  Each path that
+// can't be read is passed to report, and the rest are still expanded.
+func expandArg(arg string, report func(path string, err error)) []string {
+	fi, err := os.Stat(arg)
+	if err != nil {
+		if !os.IsNotExist(err) || !strings.ContainsAny(arg, "*?[") {
+			report(arg, err)
+			return nil
+		}
+
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			report(arg, err)
+			return nil
+		}
+		if len(matches) == 0 {
+			report(arg, errors.New("no matching files"))
+			return nil
+		}
+
+		// cards and blurred images only when the pattern asks for them
+		var files []string
+		for _, m := range matches {
+			if isGenerated(m) && !isGenerated(arg) {
+				continue
+			}
+			files = append(files, expandArg(m, report)...)
+		}
+		return files
+	}
+
+	if !fi.IsDir() {
+		return []string{arg}
+	}
+
+	var files []string
+	filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			report(path, err)
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() && loader.IsSupported(path) && !isGenerated(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files
+}
+
+// outputNames returns the name of the file card or blur writes for each
+// file, without its extension.  Outputs are named after their file, but
+// files with the same name, such as a/cover.jpg and b/cover.jpg, are told
+// apart by their folder (a-cover and b-cover), and then by a number.
+// Names are compared ignoring case, as some file systems do.
+func outputNames(files []string) map[string]string {
+	baseName := func(fn string) string {
+		base := filepath.Base(fn)
+		return strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	count := make(map[string]int)
+	for _, fn := range files {
+		count[strings.ToLower(baseName(fn))]++
+	}
+
+	names := make(map[string]string, len(files))
+	used := make(map[string]bool)
+	for _, fn := range files {
+		if _, ok := names[fn]; ok {
+			continue
+		}
+
+		name := baseName(fn)
+		if count[strings.ToLower(name)] > 1 {
+			if dir := filepath.Base(filepath.Dir(fn)); dir != "." && dir != string(filepath.Separator) {
+				name = dir + "-" + name
+			}
+		}
+
+		unique := name
+		for n := 2; used[strings.ToLower(unique)]; n++ {
+			unique = fmt.Sprintf("%s-%d", name, n)
+		}
+		used[strings.ToLower(unique)] = true
+		names[fn] = unique
+	}
+	return names
+}
+
+// processArgs expands args and processes the files like process.  It
+// returns errFailed if any argument or file failed.
+func (f *inputFlags) processArgs(args []string, fn func(filename string) (interface{}, error), emit func(filename string, v interface{}) error) error {
+	files, failed := expandArgs(args, os.Stdin)
+	err := f.process(files, fn, emit)
+	if err == nil && failed {
+		return errFailed
+	}
+	return err
+}
+
+// process calls fn for each file on -jobs goroutines, and emit with each file
+// and its result in order.  Errors from fn are logged and skipped, and
+// errFailed is returned once every file has been processed.  An error from
+// emit stops processing and is returned.
+func (f *inputFlags) process(files []string, fn func(filename string) (interface{}, error), emit func(filename string, v interface{}) error) error {
+	type result struct {
+		v   interface{}
+		err error
+	}
+
+	jobs := f.jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	results := make([]chan result, len(files))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+
+	quit := make(chan struct{})
+	defer close(quit)
+
+	next := make(chan int)
+	go func() {
+		defer close(next)
+		for i := range files {
+			select {
+			case next <- i:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < jobs; w++ {
+		go func() {
+			for i := range next {
+				v, err := fn(files[i])
+				results[i] <- result{v, err}
+			}
+		}()
+	}
+
+	failed := false
+	for i, ch := range results {
+		r := <-ch
+		if r.err != nil {
+			log.Printf("%s: %s", files[i], r.err)
+			failed = true
+			continue
+		}
+
+		if err := emit(files[i], r.v); err != nil {
+			return err
+		}
+	}
+
+	if failed {
+		return errFailed
+	}
+	return nil
+}
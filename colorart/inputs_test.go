@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func touch(t *testing.T, filename string) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filename, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpandArgs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.png", "b.txt", "d/c.jpg", "d/e/f.gif"} {
+		touch(t, filepath.Join(dir, name))
+	}
+
+	// a.png and d/c.jpg named twice are only processed once
+	files, failed := expandArgs([]string{filepath.Join(dir, "d"), filepath.Join(dir, "*.png"), "-"},
+		strings.NewReader(filepath.Join(dir, "b.txt")+"\n\n"+filepath.Join(dir, "a.png")+"\n"+dir+"/d/./c.jpg\n"))
+	want := []string{
+		filepath.Join(dir, "d/c.jpg"),
+		filepath.Join(dir, "d/e/f.gif"),
+		filepath.Join(dir, "a.png"),
+		filepath.Join(dir, "b.txt"),
+	}
+	if failed || !reflect.DeepEqual(files, want) {
+		t.Errorf("got %q (failed %v), want %q", files, failed, want)
+	}
+
+	if _, failed := expandArgs([]string{filepath.Join(dir, "*.bmp")}, nil); !failed {
+		t.Error("a glob matching nothing should fail")
+	}
+}
+
+func TestExpandGlobContinues(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, filepath.Join(dir, "a.png"))
+	touch(t, filepath.Join(dir, "z.png"))
+	if err := os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, "m.png")); err != nil {
+		t.Skip(err)
+	}
+
+	files, failed := expandArgs([]string{filepath.Join(dir, "*.png")}, nil)
+	want := []string{filepath.Join(dir, "a.png"), filepath.Join(dir, "z.png")}
+	if !failed || !reflect.DeepEqual(files, want) {
+		t.Errorf("got %q (failed %v), want %q and failed", files, failed, want)
+	}
+}
+
+func TestExpandWalkContinues(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can read every directory")
+	}
+
+	dir := t.TempDir()
+	touch(t, filepath.Join(dir, "a/1.png"))
+	touch(t, filepath.Join(dir, "b/2.png"))
+	touch(t, filepath.Join(dir, "c/3.png"))
+	locked := filepath.Join(dir, "b")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0755)
+
+	files, failed := expandArgs([]string{dir}, nil)
+	want := []string{filepath.Join(dir, "a/1.png"), filepath.Join(dir, "c/3.png")}
+	if !failed || !reflect.DeepEqual(files, want) {
+		t.Errorf("got %q (failed %v), want %q and failed", files, failed, want)
+	}
+}
+
+func TestOutputNames(t *testing.T) {
+	files := []string{
+		"a/cover.jpg",
+		"b/cover.png",
+		"b/Cover.jpg",
+		"c/front.jpg",
+		"a-cover.jpg",
+		"cover.gif",
+		"a/cover.jpg",
+	}
+	want := map[string]string{
+		"a/cover.jpg": "a-cover",
+		"b/cover.png": "b-cover",
+		"b/Cover.jpg": "b-Cover-2",
+		"c/front.jpg": "front",
+		"a-cover.jpg": "a-cover-2",
+		"cover.gif":   "cover",
+	}
+
+	if got := outputNames(files); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -3,74 +3,198 @@ package main
 //
 
 import (
-	"html/template"
+	"errors"
+	"flag"
+	"fmt"
 	"image"
-	_ "image/gif"
-	_ "image/jpeg"
-	_ "image/png"
-	"io/ioutil"
+	"io"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/disintegration/gift"
 	"github.com/sspencer/colorart"
+	"github.com/sspencer/colorart/loader"
 )
 
-const (
-	resizeThreshold = 210
-	resizeSize      = 200
-)
+type command struct {
+	name, summary string
+	run           func(args []string) error
+}
 
-type cover struct {
-	Filename, BackgroundColor, PrimaryColor, SecondaryColor, DetailColor string
+var commands = []command{
+	{"analyze", "print the colors of images, animations, videos and album folders", runAnalyze},
+	{"gallery", "write an HTML page showing each cover in its colors", runGallery},
+	{"blur", "write blurred copies of images", runBlur},
+	{"palette", "print the colors and most common colors of images", runPalette},
+	{"card", "write a PNG card showing each cover in its colors", runCard},
+	{"serve", "analyze images over HTTP", runServe},
+	{"cache", "prune or clear the cache of results", runCache},
 }
 
-func analyzeFile(filename string) (bg, c1, c2, c3 colorart.Color) {
-	file, err := os.Open(filename)
-	defer file.Close()
+// errUsage is returned when the flags or arguments of a command are wrong.
+// The usage has already been printed.
+var errUsage = errors.New("usage")
 
-	if err != nil {
-		log.Fatal(err)
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: colorart <command> [flags] [args]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.summary)
 	}
+	fmt.Fprintf(os.Stderr, "\nRun colorart <command> -help for the flags of a command.\n")
+}
 
-	img, _, err := image.Decode(file)
-	if err != nil {
-		log.Fatal(err)
+// newFlagSet creates the flags of a command, whose usage shows args and
+// summary.
+func newFlagSet(name, args, summary string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: colorart %s [flags] %s\n\n%s\n\nflags:\n", name, args, summary)
+		fs.PrintDefaults()
 	}
+	return fs
+}
+
+// parseFlags parses the flags of a command, which needs at least minArgs
+// arguments.
+func parseFlags(fs *flag.FlagSet, args []string, minArgs int) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return errUsage
+	}
+
+	if fs.NArg() < minArgs {
+		fs.Usage()
+		return errUsage
+	}
+
+	return nil
+}
+
+// imageFlags are the flags shared by the commands that analyze images.
+type imageFlags struct {
+	size        int
+	concurrency int
+	maxPixels   int64
+}
+
+func (f *imageFlags) register(fs *flag.FlagSet, size int) {
+	fs.IntVar(&f.size, "size", size, "shrink images wider or higher than this many pixels first; 0 keeps them as they are")
+	fs.IntVar(&f.concurrency, "concurrency", 0, "goroutines counting the pixels of each image; 0 uses every CPU")
+	fs.Int64Var(&f.maxPixels, "max-pixels", loader.DefaultMaxPixels, "refuse to decode images with more pixels (width times height) than this")
+}
+
+// load decodes an image, or the cover of an audio file, e-book or comic,
+// within -max-pixels.
+func (f *imageFlags) load(filename string) (image.Image, error) {
+	return loader.LoadLimit(filename, f.maxPixels)
+}
+
+func (f *imageFlags) options() *colorart.Options {
+	return &colorart.Options{Concurrency: f.concurrency}
+}
 
+// shrink resizes img to fit in -size, keeping its aspect ratio.
+func (f *imageFlags) shrink(img image.Image) image.Image {
 	b := img.Bounds()
-	if b.Max.X-b.Min.X > resizeThreshold || b.Max.Y-b.Min.Y > resizeThreshold {
-		g := gift.New(gift.Resize(resizeSize, 0, gift.LanczosResampling))
-		dst := image.NewRGBA(image.Rect(0, 0, resizeSize, resizeSize))
-		g.Draw(dst, img)
-		img = dst
+	if f.size <= 0 || b.Dx() <= f.size && b.Dy() <= f.size {
+		return img
+	}
+
+	w, h := f.size, 0
+	if b.Dy() > b.Dx() {
+		w, h = 0, f.size
 	}
 
-	return colorart.Analyze(img)
+	g := gift.New(gift.Resize(w, h, gift.LanczosResampling))
+	dst := image.NewRGBA(g.Bounds(b))
+	g.Draw(dst, img)
+	return dst
 }
 
-func main() {
+// outputFlags are the flags shared by the commands that print results.
+type outputFlags struct {
+	format    string
+	timing    bool
+	output    string
+	preview   bool
+	thumbnail int
+}
 
-	if len(os.Args) < 3 {
-		log.Fatalf("%s <template> <img 1> <img 2> ... <img n>\n", os.Args[0])
+func (f *outputFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.format, "format", "text", "output format: "+strings.Join(formats, ", "))
+	fs.BoolVar(&f.timing, "timing", false, "print how long each step took to stderr, and add it to json, ndjson, csv and tsv results")
+	fs.StringVar(&f.output, "o", "", "write results to this file instead of stdout")
+	fs.BoolVar(&f.preview, "preview", false, "with -format text, show the colors in the terminal (24 bit if COLORTERM=truecolor, none if NO_COLOR is set)")
+	fs.IntVar(&f.thumbnail, "thumbnail", 0, "with -preview, also draw the image this many characters wide")
+}
+
+// newWriter creates the writer for -format, or for -preview.  load reads
+// the images of thumbnails that aren't kept with their results.
+func (f *outputFlags) newWriter(w io.Writer, cols columns, load func(string) (image.Image, error)) (resultWriter, error) {
+	if !f.preview {
+		return newResultWriter(w, f.format, cols)
 	}
 
-	tpl, err := ioutil.ReadFile(os.Args[1])
-	if err != nil {
-		log.Fatal(err)
+	if f.format != "text" {
+		return nil, errors.New("-preview needs -format text")
 	}
+	return &previewWriter{textWriter: textWriter{w}, mode: terminalColors(), thumbnail: f.thumbnail, load: load}, nil
+}
 
-	t, err := template.New("webpage").Parse(string(tpl))
+// create opens the -o file, or stdout.
+func (f *outputFlags) create() (io.WriteCloser, error) {
+	if f.output == "" || f.output == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.Create(f.output)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
 
-	covers := make([]cover, 0, len(os.Args)-2)
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("colorart: ")
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
 
-	for i := 2; i < len(os.Args); i++ {
-		bg, c1, c2, c3 := analyzeFile(os.Args[i])
-		covers = append(covers, cover{os.Args[i], bg.String(), c1.String(), c2.String(), c3.String()})
+	name, args := os.Args[1], os.Args[2:]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		if len(args) == 0 {
+			usage()
+			return
+		}
+		name, args = args[0], []string{"-help"}
 	}
 
-	err = t.Execute(os.Stdout, covers)
-	if err != nil {
-		log.Fatal(err)
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+
+		switch err := c.run(args); err {
+		case nil:
+		case flag.ErrHelp:
+		case errUsage:
+			os.Exit(2)
+		case errFailed:
+			os.Exit(1)
+		default:
+			log.Fatal(err)
+		}
+		return
 	}
+
+	fmt.Fprintf(os.Stderr, "colorart: unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
 }
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cover is the result for one image, album, animation frame or video sample.
+type Cover struct {
+	Filename        string   `json:"file"`
+	Time            string   `json:"time,omitempty"`
+	Scene           bool     `json:"scene,omitempty"`
+	BackgroundColor string   `json:"background"`
+	PrimaryColor    string   `json:"primary"`
+	SecondaryColor  string   `json:"secondary"`
+	DetailColor     string   `json:"detail"`
+	Palette         []Swatch `json:"palette,omitempty"`
+	Timing          *Timing  `json:"timing,omitempty"`
+
+	// image is what was analyzed, for -preview thumbnails.
+	image image.Image
+}
+
+// Swatch is one of the most common colors of an image, and the share of
+// pixels it covers.
+type Swatch struct {
+	Color string  `json:"color"`
+	Share float64 `json:"share"`
+}
+
+func (s Swatch) String() string {
+	return fmt.Sprintf("%s %.1f%%", s.Color, 100*s.Share)
+}
+
+// Timing is how long each step took, in milliseconds.
+type Timing struct {
+	Resize  float64 `json:"resize_ms"`
+	Analyze float64 `json:"analyze_ms"`
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func (c *Cover) String() string {
+	name := path.Base(c.Filename)
+	if c.Time != "" {
+		name = c.Time + " " + name
+	}
+
+	return fmt.Sprintf("%s: bg=%s, primary=%s, secondary=%s, detail=%s",
+		name,
+		c.BackgroundColor,
+		c.PrimaryColor,
+		c.SecondaryColor,
+		c.DetailColor)
+}
+
+// resultWriter writes covers in one of the -format output formats.
+type resultWriter interface {
+	Write(c *Cover) error
+	// Flush writes anything buffered.  It must be called once at the end.
+	Flush() error
+}
+
+var formats = []string{"text", "json", "ndjson", "csv", "tsv"}
+
+// columns are the optional columns of csv and tsv output.
+type columns struct {
+	video, palette, timing bool
+}
+
+// newResultWriter creates a writer for format.
+func newResultWriter(w io.Writer, format string, cols columns) (resultWriter, error) {
+	switch format {
+	case "text":
+		return &textWriter{w}, nil
+	case "json":
+		return &jsonWriter{w: w, covers: []*Cover{}}, nil
+	case "ndjson":
+		return &ndjsonWriter{json.NewEncoder(w)}, nil
+	case "csv", "tsv":
+		cw := csv.NewWriter(w)
+		if format == "tsv" {
+			cw.Comma = '\t'
+		}
+		return &csvWriter{w: cw, columns: cols}, nil
+	}
+
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+type textWriter struct {
+	w io.Writer
+}
+
+func (t *textWriter) Write(c *Cover) error {
+	if c.Scene {
+		if _, err := fmt.Fprintf(t.w, "%s SCENE\n", c.Time); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintln(t.w, c); err != nil {
+		return err
+	}
+	for _, s := range c.Palette {
+		if _, err := fmt.Fprintf(t.w, "  %s\n", s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *textWriter) Flush() error { return nil }
+
+// jsonWriter writes all covers as one array when flushed.
+type jsonWriter struct {
+	w      io.Writer
+	covers []*Cover
+}
+
+func (j *jsonWriter) Write(c *Cover) error {
+	j.covers = append(j.covers, c)
+	return nil
+}
+
+func (j *jsonWriter) Flush() error {
+	enc := json.NewEncoder(j.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(j.covers)
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(c *Cover) error { return n.enc.Encode(c) }
+func (n *ndjsonWriter) Flush() error         { return nil }
+
+type csvWriter struct {
+	w *csv.Writer
+	columns
+	header bool
+}
+
+func (c *csvWriter) Write(cover *Cover) error {
+	if !c.header {
+		c.header = true
+		row := []string{"file"}
+		if c.video {
+			row = append(row, "time", "scene")
+		}
+		row = append(row, "background", "primary", "secondary", "detail")
+		if c.palette {
+			row = append(row, "palette")
+		}
+		if c.timing {
+			row = append(row, "resize_ms", "analyze_ms")
+		}
+		if err := c.w.Write(row); err != nil {
+			return err
+		}
+	}
+
+	row := []string{cover.Filename}
+	if c.video {
+		row = append(row, cover.Time, strconv.FormatBool(cover.Scene))
+	}
+	row = append(row, cover.BackgroundColor, cover.PrimaryColor, cover.SecondaryColor, cover.DetailColor)
+	if c.palette {
+		swatches := make([]string, len(cover.Palette))
+		for i, s := range cover.Palette {
+			swatches[i] = s.String()
+		}
+		row = append(row, strings.Join(swatches, ";"))
+	}
+	if c.timing {
+		var t Timing
+		if cover.Timing != nil {
+			t = *cover.Timing
+		}
+		row = append(row, strconv.FormatFloat(t.Resize, 'f', 3, 64), strconv.FormatFloat(t.Analyze, 'f', 3, 64))
+	}
+
+	return c.w.Write(row)
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
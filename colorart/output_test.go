@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func testCovers() []*Cover {
+	return []*Cover{
+		{
+			Filename:        "art/a.png",
+			BackgroundColor: "#102030",
+			PrimaryColor:    "#ffffff",
+			SecondaryColor:  "#c0c0c0",
+			DetailColor:     "#808080",
+			Palette:         []Swatch{{"#102030", 0.5}, {"#ffffff", 0.125}},
+			Timing:          &Timing{Resize: 1.5, Analyze: 2.25},
+		},
+		{
+			Filename:        "movie, cut.y4m",
+			Time:            "00:00:01.500",
+			Scene:           true,
+			BackgroundColor: "#000000",
+			PrimaryColor:    "#ff0000",
+			SecondaryColor:  "#00ff00",
+			DetailColor:     "#0000ff",
+		},
+	}
+}
+
+func TestResultWriters(t *testing.T) {
+	tests := []struct {
+		format string
+		cols   columns
+		want   string
+	}{
+		{"text", columns{}, "" +
+			"a.png: bg=#102030, primary=#ffffff, secondary=#c0c0c0, detail=#808080\n" +
+			"  #102030 50.0%\n" +
+			"  #ffffff 12.5%\n" +
+			"00:00:01.500 SCENE\n" +
+			"00:00:01.500 movie, cut.y4m: bg=#000000, primary=#ff0000, secondary=#00ff00, detail=#0000ff\n"},
+		{"json", columns{}, `[
+  {
+    "file": "art/a.png",
+    "background": "#102030",
+    "primary": "#ffffff",
+    "secondary": "#c0c0c0",
+    "detail": "#808080",
+    "palette": [
+      {
+        "color": "#102030",
+        "share": 0.5
+      },
+      {
+        "color": "#ffffff",
+        "share": 0.125
+      }
+    ],
+    "timing": {
+      "resize_ms": 1.5,
+      "analyze_ms": 2.25
+    }
+  },
+  {
+    "file": "movie, cut.y4m",
+    "time": "00:00:01.500",
+    "scene": true,
+    "background": "#000000",
+    "primary": "#ff0000",
+    "secondary": "#00ff00",
+    "detail": "#0000ff"
+  }
+]
+`},
+		{"ndjson", columns{}, "" +
+			`{"file":"art/a.png","background":"#102030","primary":"#ffffff","secondary":"#c0c0c0","detail":"#808080",` +
+			`"palette":[{"color":"#102030","share":0.5},{"color":"#ffffff","share":0.125}],"timing":{"resize_ms":1.5,"analyze_ms":2.25}}` + "\n" +
+			`{"file":"movie, cut.y4m","time":"00:00:01.500","scene":true,"background":"#000000","primary":"#ff0000","secondary":"#00ff00","detail":"#0000ff"}` + "\n"},
+		{"csv", columns{}, "" +
+			"file,background,primary,secondary,detail\n" +
+			"art/a.png,#102030,#ffffff,#c0c0c0,#808080\n" +
+			"\"movie, cut.y4m\",#000000,#ff0000,#00ff00,#0000ff\n"},
+		{"csv", columns{video: true, palette: true, timing: true}, "" +
+			"file,time,scene,background,primary,secondary,detail,palette,resize_ms,analyze_ms\n" +
+			"art/a.png,,false,#102030,#ffffff,#c0c0c0,#808080,#102030 50.0%;#ffffff 12.5%,1.500,2.250\n" +
+			"\"movie, cut.y4m\",00:00:01.500,true,#000000,#ff0000,#00ff00,#0000ff,,0.000,0.000\n"},
+		{"tsv", columns{video: true}, "" +
+			"file\ttime\tscene\tbackground\tprimary\tsecondary\tdetail\n" +
+			"art/a.png\t\tfalse\t#102030\t#ffffff\t#c0c0c0\t#808080\n" +
+			"movie, cut.y4m\t00:00:01.500\ttrue\t#000000\t#ff0000\t#00ff00\t#0000ff\n"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w, err := newResultWriter(&buf, tt.format, tt.cols)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range testCovers() {
+			if err := w.Write(c); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s %+v: got\n%s\nwant\n%s", tt.format, tt.cols, got, tt.want)
+		}
+	}
+}
+
+func TestEmptyJSON(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := newResultWriter(&buf, "json", columns{})
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "[]\n" {
+		t.Errorf("json with no covers should be an empty array, not %q", got)
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := newResultWriter(&bytes.Buffer{}, "xml", columns{}); err == nil {
+		t.Error("unknown format should fail")
+	}
+}
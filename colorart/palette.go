@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sspencer/colorart"
+)
+
+// paletter holds the flags of the palette command.
+type paletter struct {
+	inputFlags
+	imageFlags
+	outputFlags
+	cacheFlags
+
+	count int
+}
+
+func runPalette(args []string) error {
+	p := &paletter{}
+	fs := newFlagSet("palette", "<file, directory, glob or -> ...",
+		"Palette prints the colors of each image followed by its most common colors\n"+
+			"and the share of pixels each covers.")
+	p.inputFlags.register(fs)
+	p.imageFlags.register(fs, 500)
+	p.outputFlags.register(fs)
+	p.cacheFlags.register(fs)
+	fs.IntVar(&p.count, "n", 8, "number of common colors to print")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	w, err := p.create()
+	if err != nil {
+		return err
+	}
+
+	defer w.Close()
+
+	out, err := p.newWriter(w, columns{palette: true, timing: p.timing}, p.load)
+	if err != nil {
+		return err
+	}
+
+	err = p.processArgs(fs.Args(), func(fn string) (interface{}, error) {
+		key := fmt.Sprintf("palette size=%d n=%d", p.size, p.count)
+		covers, err := p.cached(fn, key, func() ([]*Cover, error) {
+			cover, err := p.palette(fn)
+			return []*Cover{cover}, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return covers[0], nil
+	}, func(_ string, v interface{}) error {
+		return out.Write(v.(*Cover))
+	})
+	if err != nil && err != errFailed {
+		return err
+	}
+	failed := err == errFailed
+
+	if err := out.Flush(); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if failed {
+		return errFailed
+	}
+	return nil
+}
+
+func (p *paletter) palette(filename string) (*Cover, error) {
+	img, err := p.load(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	img = p.shrink(img)
+	resized := time.Since(start)
+	p.logTiming("RESIZE", filename, resized)
+
+	start = time.Now()
+	opts := p.options()
+	opts.KeepHistograms = true
+	r := colorart.AnalyzeResult(img, opts)
+	analyzed := time.Since(start)
+	p.logTiming("ANALYZE", filename, analyzed)
+
+	cover := p.withImage(newCover(filename, r), img)
+	cover.Palette = palette(r.Histogram, p.count)
+
+	return p.withTiming(cover, resized, analyzed), nil
+}
+
+// palette returns the n most common colors of h.
+func palette(h colorart.Histogram, n int) []Swatch {
+	total := 0
+	h.Range(func(_ colorart.RGB, count int) {
+		total += count
+	})
+
+	var swatches []Swatch
+	for _, e := range colorart.TopK(h, n) {
+		swatches = append(swatches, Swatch{e.Color.String(), float64(e.Count) / float64(total)})
+	}
+	return swatches
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPaletteOutput(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "a.png")
+	writePNG(t, img, color.NRGBA{200, 30, 30, 255})
+	out := filepath.Join(dir, "out.txt")
+
+	if err := runPalette([]string{"-no-cache", "-n", "1", "-o", out, img}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a.png: bg=#c81c1c, primary=#ffffff, secondary=#ffffff, detail=#ffffff\n  #c81c1c 100.0%\n"
+	if got := string(data); got != want {
+		t.Errorf("-o should hold %q, not %q", want, got)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/sspencer/colorart"
+)
+
+// colorMode is how many colors the terminal shows.
+type colorMode int
+
+const (
+	noColor colorMode = iota
+	color256
+	trueColor
+)
+
+// terminalColors picks the color mode from the environment: none if
+// NO_COLOR is set, 24 bit if COLORTERM says so, otherwise 256 colors.
+func terminalColors() colorMode {
+	if os.Getenv("NO_COLOR") != "" {
+		return noColor
+	}
+
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return trueColor
+	}
+	return color256
+}
+
+// sgr returns the escape sequence setting the foreground (38) or
+// background (48) color to c.
+func (m colorMode) sgr(layer int, c colorart.RGB) string {
+	switch m {
+	case trueColor:
+		return fmt.Sprintf("\x1b[%d;2;%d;%d;%dm", layer, c[0], c[1], c[2])
+	case color256:
+		return fmt.Sprintf("\x1b[%d;5;%dm", layer, xterm256(c))
+	}
+	return ""
+}
+
+const reset = "\x1b[0m"
+
+// xterm256 returns the closest color of the xterm 256 color palette: the
+// 6x6x6 color cube or the 24 step gray ramp.
+func xterm256(c colorart.RGB) int {
+	// cube levels are 0, 95, 135, 175, 215 and 255
+	level := func(v uint8) int {
+		if v < 48 {
+			return 0
+		}
+		if v < 115 {
+			return 1
+		}
+		return (int(v) - 35) / 40
+	}
+	value := func(l int) int {
+		if l == 0 {
+			return 0
+		}
+		return 55 + 40*l
+	}
+
+	r, g, b := level(c[0]), level(c[1]), level(c[2])
+	cube := 16 + 36*r + 6*g + b
+	cubeDist := dist(c, value(r), value(g), value(b))
+
+	// gray levels are 8, 18, ... 238
+	avg := (int(c[0]) + int(c[1]) + int(c[2])) / 3
+	gray := (avg - 3) / 10
+	if gray < 0 {
+		gray = 0
+	} else if gray > 23 {
+		gray = 23
+	}
+	v := 8 + 10*gray
+	if dist(c, v, v, v) < cubeDist {
+		return 232 + gray
+	}
+	return cube
+}
+
+func dist(c colorart.RGB, r, g, b int) int {
+	dr, dg, db := int(c[0])-r, int(c[1])-g, int(c[2])-b
+	return dr*dr + dg*dg + db*db
+}
+
+// previewWriter writes text results followed by a swatch of the colors in
+// the terminal, and optionally a thumbnail of the image drawn with half
+// blocks, two pixels per character.
+type previewWriter struct {
+	textWriter
+	mode colorMode
+
+	// thumbnail is the width of thumbnails in characters, 0 for none.
+	thumbnail int
+
+	// load reads images for covers that don't have one, such as those
+	// from the cache.
+	load func(filename string) (image.Image, error)
+}
+
+func (p *previewWriter) Write(c *Cover) error {
+	if err := p.textWriter.Write(c); err != nil {
+		return err
+	}
+	if p.mode == noColor {
+		return nil
+	}
+
+	var buf strings.Builder
+	bg := parseRGB(c.BackgroundColor)
+	buf.WriteString("  " + p.mode.sgr(48, bg) + " ")
+	for _, s := range []struct{ name, color string }{
+		{"Primary", c.PrimaryColor},
+		{"Secondary", c.SecondaryColor},
+		{"Detail", c.DetailColor},
+	} {
+		buf.WriteString(p.mode.sgr(38, parseRGB(s.color)) + s.name + " ")
+	}
+	buf.WriteString(reset + "\n")
+
+	if len(c.Palette) > 0 {
+		buf.WriteString("  ")
+		for _, s := range c.Palette {
+			buf.WriteString(p.mode.sgr(48, parseRGB(s.Color)) + "   ")
+		}
+		buf.WriteString(reset + "\n")
+	}
+
+	if p.thumbnail > 0 {
+		if img := p.thumbnailImage(c); img != nil {
+			p.drawThumbnail(&buf, img)
+		}
+		// -watch keeps the results, but not their images
+		c.image = nil
+	}
+
+	_, err := io.WriteString(p.w, buf.String())
+	return err
+}
+
+// thumbnailImage returns the image analyzed for c, loading it again for
+// covers without one, such as those from the cache.  Only regular files
+// are loaded: album folders, animation frames (file.gif#2) and video
+// samples have no thumbnail.
+func (p *previewWriter) thumbnailImage(c *Cover) image.Image {
+	if c.image != nil {
+		return c.image
+	}
+	if p.load == nil || c.Time != "" {
+		return nil
+	}
+	if fi, err := os.Stat(c.Filename); err != nil || !fi.Mode().IsRegular() {
+		return nil
+	}
+
+	img, err := p.load(c.Filename)
+	if err != nil {
+		log.Printf("%s: %s", c.Filename, err)
+		return nil
+	}
+	return img
+}
+
+// drawThumbnail draws img thumbnail characters wide.  Each character is a
+// top half block colored with the upper pixel on the lower one.
+func (p *previewWriter) drawThumbnail(buf *strings.Builder, img image.Image) {
+	b := img.Bounds()
+	if b.Empty() {
+		return
+	}
+
+	w := p.thumbnail
+	h := (w*b.Dy()/b.Dx() + 1) / 2 * 2
+	if h < 2 {
+		h = 2
+	}
+
+	at := func(x, y int) colorart.RGB {
+		r, g, bl, a := img.At(b.Min.X+x*b.Dx()/w, b.Min.Y+y*b.Dy()/h).RGBA()
+		if a == 0 {
+			return colorart.RGB{}
+		}
+		return colorart.RGBAToColor(r, g, bl, a).RGB()
+	}
+
+	for y := 0; y < h; y += 2 {
+		buf.WriteString("  ")
+		for x := 0; x < w; x++ {
+			buf.WriteString(p.mode.sgr(38, at(x, y)) + p.mode.sgr(48, at(x, y+1)) + "▀")
+		}
+		buf.WriteString(reset + "\n")
+	}
+}
+
+// parseRGB parses a hex color of a Cover.
+func parseRGB(s string) colorart.RGB {
+	var c colorart.RGB
+	c.UnmarshalText([]byte(s))
+	return c
+}
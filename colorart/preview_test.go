@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sspencer/colorart"
+)
+
+func TestXterm256(t *testing.T) {
+	tests := []struct {
+		c    colorart.RGB
+		want int
+	}{
+		// the color cube
+		{colorart.RGB{255, 0, 0}, 196},
+		{colorart.RGB{95, 135, 175}, 67},
+		{colorart.RGB{0, 0, 0}, 16},
+		{colorart.RGB{255, 255, 255}, 231},
+		{colorart.RGB{250, 250, 250}, 231},
+
+		// the gray ramp
+		{colorart.RGB{128, 128, 128}, 244},
+		{colorart.RGB{50, 50, 50}, 236},
+
+		// grays past either end of the ramp clamp to it
+		{colorart.RGB{5, 5, 5}, 232},
+		{colorart.RGB{245, 245, 245}, 255},
+	}
+
+	for _, tt := range tests {
+		if got := xterm256(tt.c); got != tt.want {
+			t.Errorf("xterm256(%v) should be %d, not %d", tt.c, tt.want, got)
+		}
+	}
+}
+
+func TestTerminalColors(t *testing.T) {
+	tests := []struct {
+		noColor, colorTerm string
+		want               colorMode
+	}{
+		{"", "", color256},
+		{"", "truecolor", trueColor},
+		{"", "24BIT", trueColor},
+		{"", "yes", color256},
+		{"1", "truecolor", noColor},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("NO_COLOR", tt.noColor)
+		t.Setenv("COLORTERM", tt.colorTerm)
+		if got := terminalColors(); got != tt.want {
+			t.Errorf("NO_COLOR=%q COLORTERM=%q: mode should be %d, not %d", tt.noColor, tt.colorTerm, tt.want, got)
+		}
+	}
+
+	c := colorart.RGB{255, 0, 0}
+	if s := trueColor.sgr(38, c); s != "\x1b[38;2;255;0;0m" {
+		t.Errorf("24 bit foreground is %q", s)
+	}
+	if s := color256.sgr(48, c); s != "\x1b[48;5;196m" {
+		t.Errorf("256 color background is %q", s)
+	}
+	if s := noColor.sgr(38, c); s != "" {
+		t.Errorf("no color should write nothing, not %q", s)
+	}
+}
+
+func TestPreviewThumbnail(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.png")
+	touch(t, file)
+
+	var loaded []string
+	var buf bytes.Buffer
+	p := &previewWriter{textWriter: textWriter{&buf}, mode: trueColor, thumbnail: 4,
+		load: func(filename string) (image.Image, error) {
+			loaded = append(loaded, filename)
+			return image.NewRGBA(image.Rect(0, 0, 4, 4)), nil
+		},
+	}
+
+	covers := []*Cover{
+		{Filename: dir},
+		{Filename: file + "#2"},
+		{Filename: file, Time: "00:00:01.000"},
+		{Filename: file},
+	}
+	for _, c := range covers {
+		c.BackgroundColor, c.PrimaryColor, c.SecondaryColor, c.DetailColor = "#000000", "#ffffff", "#ffffff", "#ffffff"
+		if err := p.Write(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(loaded) != 1 || loaded[0] != file {
+		t.Errorf("only the regular file should be loaded, not %q", loaded)
+	}
+	if n := strings.Count(buf.String(), "▀"); n != 4*4/2 {
+		t.Errorf("one 4x2 thumbnail should be drawn, got %d half blocks", n)
+	}
+}
+
+func TestKeepImage(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.png")
+	writePNG(t, file, color.NRGBA{200, 30, 30, 255})
+
+	a := &analyzer{}
+	a.size = 100
+	if c, err := a.analyzeFile(file); err != nil || c.image != nil {
+		t.Errorf("the image should only be kept for thumbnails: %v", err)
+	}
+
+	a.preview, a.thumbnail = true, 4
+	c, err := a.analyzeFile(file)
+	if err != nil || c.image == nil {
+		t.Fatalf("the image should be kept for thumbnails: %v", err)
+	}
+
+	p := &previewWriter{textWriter: textWriter{&bytes.Buffer{}}, mode: trueColor, thumbnail: 4}
+	if err := p.Write(c); err != nil {
+		t.Fatal(err)
+	}
+	if c.image != nil {
+		t.Error("the image should be dropped once its thumbnail is drawn")
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/sspencer/colorart"
+	"github.com/sspencer/colorart/server"
+)
+
+func runServe(args []string) error {
+	var f imageFlags
+	var addr string
+	var maxUpload int64
+	fs := newFlagSet("serve", "",
+		"Serve analyzes images over HTTP.  POST an image, or a multipart form of\n"+
+			"images, to /analyze for JSON results; GET /health reports the server is up.")
+	f.register(fs, 500)
+	fs.StringVar(&addr, "addr", ":8080", "address to listen on")
+	fs.Int64Var(&maxUpload, "max-upload", server.DefaultMaxUploadSize, "largest request body accepted, in bytes")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	// bound the counting goroutines of all requests together
+	opts := f.options()
+	opts.Pool = colorart.NewPool(0)
+
+	srv := &http.Server{
+		Addr: addr,
+		Handler: server.NewHandler(&server.Options{
+			MaxUploadSize: maxUpload,
+			MaxPixels:     f.maxPixels,
+			Size:          f.size,
+			Analyze:       opts,
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+		WriteTimeout:      time.Minute,
+	}
+
+	log.Printf("listening on %s", addr)
+	return srv.ListenAndServe()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// watchFlags are the flags of the commands that can watch their inputs.
+type watchFlags struct {
+	watch    bool
+	interval time.Duration
+}
+
+func (f *watchFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.watch, "watch", false, "keep running, and process files again as they are added or change")
+	fs.DurationVar(&f.interval, "interval", time.Second, "with -watch, how often to look for changes")
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// watchArgs expands args every -interval, and calls update with the files
+// that are new or have changed since the last call, and those that are gone.
+// The first call has every file.  It only returns if update fails.
+func (f *watchFlags) watchArgs(args []string, update func(changed, removed []string) error) error {
+	for _, arg := range args {
+		if arg == "-" {
+			return errors.New("-watch can't read a list of files from stdin")
+		}
+	}
+
+	var seen map[string]fileState
+	for first := true; ; first = false {
+		files, current := statFiles(args, func(path string, err error) {
+			if first {
+				log.Printf("%s: %s", path, err)
+			}
+		})
+
+		changed, removed := diffFiles(seen, files, current)
+		seen = current
+
+		if first || len(changed) > 0 || len(removed) > 0 {
+			if err := update(changed, removed); err != nil {
+				return err
+			}
+		}
+
+		time.Sleep(f.interval)
+	}
+}
+
+// statFiles expands args and returns the files in order, and the state of
+// each.  Files that can't be expanded or read are passed to report.
+func statFiles(args []string, report func(path string, err error)) ([]string, map[string]fileState) {
+	var files []string
+	states := make(map[string]fileState)
+	for _, arg := range args {
+		for _, fn := range expandArg(arg, report) {
+			if _, ok := states[fn]; ok {
+				continue
+			}
+
+			fi, err := os.Stat(fn)
+			if err != nil {
+				continue
+			}
+
+			files = append(files, fn)
+			states[fn] = fileState{fi.Size(), fi.ModTime()}
+		}
+	}
+	return files, states
+}
+
+// diffFiles compares the files now, with their states in current, to those
+// seen before.  It returns the files that are new or have changed, in the
+// order of files, and those that are gone, sorted.
+func diffFiles(seen map[string]fileState, files []string, current map[string]fileState) (changed, removed []string) {
+	for _, fn := range files {
+		st := current[fn]
+		if old, ok := seen[fn]; !ok || old.size != st.size || !old.modTime.Equal(st.modTime) {
+			changed = append(changed, fn)
+		}
+	}
+
+	for fn := range seen {
+		if _, ok := current[fn]; !ok {
+			removed = append(removed, fn)
+		}
+	}
+	sort.Strings(removed)
+	return changed, removed
+}
+
+// writeFileAtomic replaces path with data, so readers never see a partly
+// written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
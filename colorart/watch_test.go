@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffFiles(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	seen := map[string]fileState{
+		"same.png":  {100, t0},
+		"grown.png": {100, t0},
+		"newer.png": {100, t0},
+		"gone.png":  {100, t0},
+		"alsogone":  {100, t0},
+	}
+	files := []string{"z-new.png", "same.png", "newer.png", "grown.png", "a-new.png"}
+	current := map[string]fileState{
+		"z-new.png": {10, t0},
+		"same.png":  {100, t0},
+		"newer.png": {100, t0.Add(time.Second)},
+		"grown.png": {200, t0},
+		"a-new.png": {10, t0},
+	}
+
+	changed, removed := diffFiles(seen, files, current)
+	if want := []string{"z-new.png", "newer.png", "grown.png", "a-new.png"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed should be %q, not %q", want, changed)
+	}
+	if want := []string{"alsogone", "gone.png"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed should be %q, not %q", want, removed)
+	}
+
+	// the first pass has seen nothing
+	changed, removed = diffFiles(nil, files, current)
+	if !reflect.DeepEqual(changed, files) || len(removed) != 0 {
+		t.Errorf("every file should be new the first time: %q, %q", changed, removed)
+	}
+
+	if changed, removed := diffFiles(current, files, current); len(changed) != 0 || len(removed) != 0 {
+		t.Errorf("nothing should change: %q, %q", changed, removed)
+	}
+}
+
+func TestStatFiles(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.png")
+	touch(t, a)
+	touch(t, filepath.Join(dir, "d/b.png"))
+	if err := os.WriteFile(a, []byte("1234"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var reported []string
+	files, states := statFiles([]string{a, dir, filepath.Join(dir, "*.gif")}, func(path string, err error) {
+		reported = append(reported, path)
+	})
+
+	want := []string{a, filepath.Join(dir, "d/b.png")}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("files should be %q, listed once, not %q", want, files)
+	}
+	if states[a].size != 4 || len(states) != 2 {
+		t.Errorf("unexpected states %+v", states)
+	}
+	if len(reported) != 1 {
+		t.Errorf("the glob matching nothing should be reported, got %q", reported)
+	}
+}
@@ -0,0 +1,313 @@
+package colorart
+
+import (
+	"image"
+	"image/color"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testImage returns a deterministic, many colored image.
+func testImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x * 7), uint8(y * 3), uint8((x + y) * 5), 255})
+		}
+	}
+
+	// solid border so there is a clear background color
+	for y := 0; y < h; y++ {
+		img.SetNRGBA(0, y, color.NRGBA{20, 40, 120, 255})
+		img.SetNRGBA(w-1, y, color.NRGBA{20, 40, 120, 255})
+	}
+
+	return img
+}
+
+func countWith(img image.Image, opts Options) Histogram {
+	c := &colorArt{img: newPixelGetter(img), opts: opts}
+	return c.countImageColors()
+}
+
+func sameCounts(a, b Histogram) bool {
+	same := a.Len() == b.Len()
+	a.Range(func(color RGB, cnt int) {
+		if b.Count(color) != cnt {
+			same = false
+		}
+	})
+	return same
+}
+
+func TestConcurrency(t *testing.T) {
+	img := testImage(97, 61)
+	want := countWith(img, Options{Concurrency: 1})
+
+	pool := NewPool(3)
+	for _, opts := range []Options{{}, {Concurrency: 4}, {Concurrency: 1000}, {Pool: pool}} {
+		if got := countWith(img, opts); !sameCounts(got, want) {
+			t.Errorf("%+v: counted colors differ from single threaded count", opts)
+		}
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	// lots of colors with equal counts
+	img := testImage(97, 61)
+	bg, c1, c2, c3 := AnalyzeWithOptions(img, &Options{Concurrency: 1})
+
+	pool := NewPool(3)
+	for i := 0; i < 50; i++ {
+		for _, opts := range []*Options{nil, {Concurrency: 1}, {Concurrency: 5}, {Pool: pool}} {
+			b, p1, p2, p3 := AnalyzeWithOptions(img, opts)
+			if b != bg || p1 != c1 || p2 != c2 || p3 != c3 {
+				t.Fatalf("run %d %+v: got %s %s %s %s, want %s %s %s %s", i, opts, b, p1, p2, p3, bg, c1, c2, c3)
+			}
+		}
+	}
+}
+
+func TestKeepHistograms(t *testing.T) {
+	img := testImage(40, 30)
+
+	if r := AnalyzeResult(img, nil); r.Histogram != nil || r.EdgeHistogram != nil {
+		t.Error("histograms should only be kept when asked for")
+	}
+
+	r := AnalyzeResult(img, &Options{KeepHistograms: true})
+	if r.Histogram == nil || r.EdgeHistogram == nil {
+		t.Fatal("histograms should be kept")
+	}
+
+	if n := r.EdgeHistogram.Count(RGB{20, 40, 120}); n != 60 {
+		t.Errorf("edge count should be 60, not %d", n)
+	}
+
+	if r.Histogram.Len() == 0 {
+		t.Error("image histogram should not be empty")
+	}
+}
+
+func TestAnalyzeRows(t *testing.T) {
+	// odd sized image not starting at 0,0
+	img := testImage(81, 57).SubImage(image.Rect(3, 5, 80, 56))
+
+	want := AnalyzeResult(img, &Options{KeepHistograms: true})
+	got, err := AnalyzeRows(ImageRows(img), &Options{KeepHistograms: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !sameCounts(got.Histogram, want.Histogram) || !sameCounts(got.EdgeHistogram, want.EdgeHistogram) {
+		t.Error("streamed histograms differ from Analyze")
+	}
+	if got.Background != want.Background || got.Primary != want.Primary ||
+		got.Secondary != want.Secondary || got.Detail != want.Detail {
+		t.Errorf("AnalyzeRows should match Analyze: %v, %v", got, want)
+	}
+}
+
+func TestAnalyzeRows16(t *testing.T) {
+	// values that round differently at 8 and 16 bits
+	rgba := image.NewNRGBA64(image.Rect(0, 0, 64, 48))
+	gray := image.NewGray16(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			v := uint16(x*1021 + y*7)
+			rgba.SetNRGBA64(x, y, color.NRGBA64{v, uint16(y * 1361), v ^ 0x5a5a, 0xffff})
+			gray.SetGray16(x, y, color.Gray16{v})
+		}
+	}
+
+	for _, img := range []image.Image{rgba, gray} {
+		want := AnalyzeResult(img, &Options{KeepHistograms: true})
+		got, err := AnalyzeRows(ImageRows(img), &Options{KeepHistograms: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !sameCounts(got.Histogram, want.Histogram) || !sameCounts(got.EdgeHistogram, want.EdgeHistogram) {
+			t.Errorf("%T: streamed histograms differ from Analyze", img)
+		}
+		if got.Background != want.Background || got.Primary != want.Primary ||
+			got.Secondary != want.Secondary || got.Detail != want.Detail {
+			t.Errorf("%T: AnalyzeRows should match Analyze: %v, %v", img, got, want)
+		}
+	}
+}
+
+func TestAnalyzeFrames(t *testing.T) {
+	solid := func(c color.NRGBA) image.Image {
+		img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+		for i := 0; i < len(img.Pix); i += 4 {
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+		}
+		return img
+	}
+
+	red := solid(color.NRGBA{200, 40, 40, 255})
+	green := solid(color.NRGBA{40, 200, 40, 255})
+
+	r := AnalyzeFrames([]Frame{{red, 100 * time.Millisecond}, {green, time.Second}}, nil)
+	if want := (RGB{40, 200, 40}).Color(); r.Background != want {
+		t.Errorf("longest frame should set the background %s, not %s", want, r.Background)
+	}
+
+	each := AnalyzeEachFrame([]Frame{{red, 0}, {green, 0}}, nil)
+	if len(each) != 2 || each[0].Background == each[1].Background {
+		t.Error("each frame should be analyzed on its own")
+	}
+}
+
+func TestSmoother(t *testing.T) {
+	black := Result{Background: BlackColor, Primary: BlackColor, Secondary: BlackColor, Detail: BlackColor}
+	white := Result{Background: WhiteColor, Primary: WhiteColor, Secondary: WhiteColor, Detail: WhiteColor}
+
+	s := NewSmoother(0.5)
+	if r := s.Update(black); r.Background != BlackColor {
+		t.Errorf("first result should be unchanged, not %s", r.Background)
+	}
+	if r := s.Update(white); r.Background.String() != "#777777" {
+		t.Errorf("background should be perceptually halfway to white, not %s", r.Background)
+	}
+
+	gray := s.Update(white).Background
+	s.Hysteresis = 100
+	if r := s.Update(black); r.Background != gray {
+		t.Errorf("background within hysteresis should not move from %s, not %s", gray, r.Background)
+	}
+
+	s.Hysteresis = 0
+	s.SceneThreshold = 50
+	if r := s.Update(white); r.Background == gray || r.Background == WhiteColor || s.SceneChange() {
+		t.Errorf("small change should not start a scene: %s, %v", r.Background, s.SceneChange())
+	}
+	if r := s.Update(black); r.Background != BlackColor || !s.SceneChange() {
+		t.Errorf("large change should start a scene: %s, %v", r.Background, s.SceneChange())
+	}
+
+	// fallback flags follow the newest result, smoothed or not
+	s = NewSmoother(0.5)
+	fallback := white
+	fallback.PrimaryFallback, fallback.DetailFallback = true, true
+	if r := s.Update(fallback); !r.PrimaryFallback || r.SecondaryFallback || !r.DetailFallback {
+		t.Errorf("first result should keep its fallback flags: %+v", r)
+	}
+	if r := s.Update(black); r.PrimaryFallback || r.DetailFallback {
+		t.Errorf("fallback flags should be cleared by a result without them: %+v", r)
+	}
+	if r := s.Update(fallback); !r.PrimaryFallback || !r.DetailFallback {
+		t.Errorf("smoothed result should have the newest fallback flags: %+v", r)
+	}
+}
+
+func TestLab(t *testing.T) {
+	for _, c := range []Color{BlackColor, WhiteColor, {0.2, 0.4, 0.6, true}, {1, 0, 0, true}} {
+		l, a, b := c.Lab()
+		d := LabToColor(l, a, b)
+		if c.DeltaE(d) > 0.01 {
+			t.Errorf("%s does not survive conversion to Lab and back: %s", c, d)
+		}
+	}
+
+	if l, _, _ := WhiteColor.Lab(); l < 99.99 || l > 100.01 {
+		t.Errorf("L of white should be 100, not %g", l)
+	}
+}
+
+func TestContrastRatio(t *testing.T) {
+	if r := BlackColor.ContrastRatio(WhiteColor); r < 20.99 || r > 21.01 {
+		t.Errorf("contrast of black and white should be 21, not %g", r)
+	}
+	if r := WhiteColor.ContrastRatio(BlackColor); r < 20.99 || r > 21.01 {
+		t.Errorf("contrast should not depend on order, got %g", r)
+	}
+	if r := (Color{0.5, 0.5, 0.5, true}).ContrastRatio(Color{0.5, 0.5, 0.5, true}); r != 1 {
+		t.Errorf("contrast of a color with itself should be 1, not %g", r)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	// a single color leaves nothing to contrast with the background
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+
+	r := AnalyzeResult(img, nil)
+	if !r.PrimaryFallback || !r.SecondaryFallback || !r.DetailFallback {
+		t.Errorf("text colors of a plain image should be fallbacks: %+v", r)
+	}
+	if r.Primary != BlackColor {
+		t.Errorf("text on white should fall back to black, not %s", r.Primary)
+	}
+}
+
+func TestSharedPool(t *testing.T) {
+	img := testImage(64, 64)
+	bg, _, _, _ := Analyze(img)
+
+	pool := NewPool(2)
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b, _, _, _ := AnalyzeWithOptions(img, &Options{Pool: pool}); b != bg {
+				t.Errorf("background should be %s, not %s", bg, b)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTinyImage(t *testing.T) {
+	// fewer rows than goroutines
+	img := testImage(3, 2)
+	if !sameCounts(countWith(img, Options{Concurrency: 8}), countWith(img, Options{Concurrency: 1})) {
+		t.Error("counted colors differ from single threaded count")
+	}
+
+	bg, c1, c2, c3 := AnalyzeWithOptions(img, &Options{Concurrency: 1})
+	if b, p1, p2, p3 := AnalyzeWithOptions(img, &Options{Concurrency: 8}); b != bg || p1 != c1 || p2 != c2 || p3 != c3 {
+		t.Errorf("got %s %s %s %s, want %s %s %s %s", b, p1, p2, p3, bg, c1, c2, c3)
+	}
+}
+
+func TestAnalyzeBatch(t *testing.T) {
+	items := []BatchItem{
+		{Image: testImage(40, 30)},
+		{Filename: "does-not-exist.png"},
+		{Image: testImage(30, 40)},
+	}
+
+	results := AnalyzeBatch(items, &BatchOptions{Workers: 2})
+	if len(results) != len(items) {
+		t.Fatalf("got %d results, want %d", len(results), len(items))
+	}
+
+	for i, r := range results {
+		if r.Index != i {
+			t.Errorf("result %d has index %d", i, r.Index)
+		}
+	}
+
+	if results[1].Err == nil {
+		t.Error("missing file should return an error")
+	}
+
+	bg, _, _, _ := Analyze(items[0].Image)
+	if results[0].Err != nil || results[0].Result.Background != bg {
+		t.Errorf("background should be %s, not %s (%v)", bg, results[0].Result.Background, results[0].Err)
+	}
+}
+
+func BenchmarkAnalyze(b *testing.B) {
+	img := testImage(200, 200)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Analyze(img)
+	}
+}
@@ -0,0 +1,105 @@
+package colorart
+
+import (
+	"image/color"
+	"sort"
+	"sync/atomic"
+)
+
+// ConcurrentSet counts colors like a DenseSet, but any number of goroutines
+// may add to it at the same time without locking.  Colors are detuned when
+// counted, so AddCount accepts any RGB.  Len, Count and Range may be called
+// while colors are being added, but only see a consistent set of counts
+// once every producer has finished.
+type ConcurrentSet struct {
+	counts []uint32
+	n      int64
+}
+
+// NewConcurrentSet creates an empty ConcurrentSet.
+func NewConcurrentSet() *ConcurrentSet {
+	return &ConcurrentSet{counts: make([]uint32, denseSize)}
+}
+
+func (s *ConcurrentSet) add(i int, count uint32) {
+	if atomic.AddUint32(&s.counts[i], count) == count {
+		atomic.AddInt64(&s.n, 1)
+	}
+}
+
+// AddPixel converts pixel to a detuned RGB key and counts unique colors
+func (s *ConcurrentSet) AddPixel(p pixel) {
+	b := uint8(colorShifter)
+	ri := uint8(maxComponent*p.R) >> b
+	gi := uint8(maxComponent*p.G) >> b
+	bi := uint8(maxComponent*p.B) >> b
+
+	s.add(int(ri)<<(2*denseBits)|int(gi)<<denseBits|int(bi), 1)
+}
+
+// AddColor detunes c and counts it, the way a pixel of an image is.
+func (s *ConcurrentSet) AddColor(c color.Color) {
+	s.AddPixel(colorPixel(c))
+}
+
+// AddCount adds count to color.  Counts stop at math.MaxUint32 rather
+// than wrapping.
+func (s *ConcurrentSet) AddCount(color RGB, count int) {
+	if count <= 0 {
+		return
+	}
+
+	i := denseIndex(color)
+	for {
+		old := atomic.LoadUint32(&s.counts[i])
+		n := addSaturating(old, count)
+		if n == old {
+			return
+		}
+		if atomic.CompareAndSwapUint32(&s.counts[i], old, n) {
+			if old == 0 {
+				atomic.AddInt64(&s.n, 1)
+			}
+			return
+		}
+	}
+}
+
+// Count returns the number of times color has been counted.
+func (s *ConcurrentSet) Count(color RGB) int {
+	return int(atomic.LoadUint32(&s.counts[denseIndex(color)]))
+}
+
+// Len returns the number of distinct colors.
+func (s *ConcurrentSet) Len() int {
+	return int(atomic.LoadInt64(&s.n))
+}
+
+// Range calls fn for every color with a non zero count.
+func (s *ConcurrentSet) Range(fn func(color RGB, count int)) {
+	for i := range s.counts {
+		if cnt := atomic.LoadUint32(&s.counts[i]); cnt != 0 {
+			fn(denseColor(i), int(cnt))
+		}
+	}
+}
+
+// SortedSet returns the entries (Color, Count) ordered from greatest count to least.
+// Entries with the same count are ordered by color.
+func (s *ConcurrentSet) SortedSet() []CountedEntry {
+	list := make([]CountedEntry, 0, s.Len())
+	s.Range(func(color RGB, cnt int) {
+		list = append(list, CountedEntry{color, cnt})
+	})
+
+	sort.Sort(ByCount(list))
+	return list
+}
+
+// Snapshot copies the current counts into a DenseSet, which can then be
+// serialized or analyzed without further atomic loads.
+func (s *ConcurrentSet) Snapshot() *DenseSet {
+	d := NewDenseSet()
+	s.Range(d.AddCount)
+	return d
+}
@@ -2,21 +2,39 @@ package colorart
 
 import (
 	"fmt"
+	"image/color"
 	"sort"
 )
 
 const maxComponent = 255
 
-// rgb is RGB components, 0-255, used as the map key
-type rgb [3]byte
+// RGB is the red, green and blue components, 0-255, of a color.  It is
+// the key colors are counted by.
+type RGB [3]byte
+
+// Color converts the key to a Color.
+func (k RGB) Color() Color {
+	return Color{float64(k[0]) / 255.0, float64(k[1]) / 255.0, float64(k[2]) / 255.0, true}
+}
+
+// Detune returns the key that pixels of this color are counted under.
+func (k RGB) Detune() RGB {
+	b := uint8(colorShifter)
+	return RGB{k[0] >> b << b, k[1] >> b << b, k[2] >> b << b}
+}
+
+// String returns a HTML hex code string for the key (#9a45bc)
+func (k RGB) String() string {
+	return fmt.Sprintf("#%02x%02x%02x", k[0], k[1], k[2])
+}
 
 // CountedSet counts the number of times each object (string) is added to the set.
-// The set is not thread safe.
-type CountedSet map[rgb]int
+// The set is not thread safe; see ConcurrentSet.
+type CountedSet map[RGB]int
 
 // CountedEntry is for use by sorting class
 type CountedEntry struct {
-	Color rgb
+	Color RGB
 	Count int
 }
 
@@ -25,7 +43,7 @@ type ByCount []CountedEntry
 
 func (a ByCount) Len() int           { return len(a) }
 func (a ByCount) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a ByCount) Less(i, j int) bool { return a[i].Count > a[j].Count }
+func (a ByCount) Less(i, j int) bool { return entryBefore(a[i], a[j]) }
 
 func (e CountedEntry) String() string {
 	return fmt.Sprintf("%02x%02x%02x: %d", e.Color[0], e.Color[1], e.Color[2], e.Count)
@@ -35,11 +53,11 @@ func (e CountedEntry) String() string {
 
 // NewCountedSet creates a new CountedSet of the specified size.
 func NewCountedSet(size int) CountedSet {
-	s := make(map[rgb]int, size)
+	s := make(map[RGB]int, size)
 	return s
 }
 
-// AddPixel converts pixel to [3]byte rgb and counts unique colors
+// AddPixel converts pixel to a detuned RGB key and counts unique colors
 func (s CountedSet) AddPixel(p pixel) {
 
 	b := uint8(colorShifter)
@@ -47,11 +65,16 @@ func (s CountedSet) AddPixel(p pixel) {
 	gi := uint8(maxComponent*p.G) >> b << b
 	bi := uint8(maxComponent*p.B) >> b << b
 
-	color := rgb{ri, gi, bi}
+	color := RGB{ri, gi, bi}
 
 	s[color]++
 }
 
+// AddColor detunes c and counts it, the way a pixel of an image is.
+func (s CountedSet) AddColor(c color.Color) {
+	s.AddPixel(colorPixel(c))
+}
+
 // Merge other counted set into this one.
 func (s CountedSet) Merge(o CountedSet) {
 	for color, cnt := range o {
@@ -60,16 +83,32 @@ func (s CountedSet) Merge(o CountedSet) {
 }
 
 // Add color with count.
-func (s CountedSet) AddCount(color rgb, count int) {
+func (s CountedSet) AddCount(color RGB, count int) {
+	if count <= 0 {
+		return
+	}
 	s[color] += count
 }
 
+// Len returns the number of distinct colors.
+func (s CountedSet) Len() int {
+	return len(s)
+}
+
+// Range calls fn for every color in the set.
+func (s CountedSet) Range(fn func(color RGB, count int)) {
+	for color, cnt := range s {
+		fn(color, cnt)
+	}
+}
+
 // Count returns the number of times the specified object has been added to the set.
-func (s CountedSet) Count(color rgb) int {
+func (s CountedSet) Count(color RGB) int {
 	return s[color]
 }
 
-// SortedSet returns the entries (Color, Count) ordered from greatest count to least
+// SortedSet returns the entries (Color, Count) ordered from greatest count to least.
+// Entries with the same count are ordered by color.
 func (s CountedSet) SortedSet() []CountedEntry {
 
 	list := make([]CountedEntry, len(s))
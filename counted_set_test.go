@@ -1,11 +1,18 @@
 package colorart
 
-import "testing"
+import (
+	"image"
+	"math"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
 
 var (
-	zero = rgb{0, 100, 200}
-	one  = rgb{1, 11, 111}
-	two  = rgb{2, 22, 222}
+	zero = RGB{0, 100, 200}
+	one  = RGB{1, 11, 111}
+	two  = RGB{2, 22, 222}
 )
 
 func TestAddPixel(t *testing.T) {
@@ -14,7 +21,7 @@ func TestAddPixel(t *testing.T) {
 	entries := s.SortedSet()
 	e := entries[0]
 	str := e.String()
-	answer := "336699: 1"
+	answer := "306498: 1"
 	if str != answer {
 		t.Errorf("String conversion should be %s, not %s", answer, str)
 	}
@@ -89,6 +96,20 @@ func TestSortedSet(t *testing.T) {
 	}
 }
 
+func TestSortedSetTies(t *testing.T) {
+	s := NewCountedSet(10)
+	s.AddCount(two, 1)
+	s.AddCount(zero, 1)
+	s.AddCount(one, 1)
+
+	for i := 0; i < 20; i++ {
+		entries := s.SortedSet()
+		if entries[0].Color != zero || entries[1].Color != one || entries[2].Color != two {
+			t.Fatalf("equal counts should be ordered by color, not %v", entries)
+		}
+	}
+}
+
 func TestAddCount(t *testing.T) {
 	s := NewCountedSet(10)
 
@@ -109,6 +130,32 @@ func TestAddCount(t *testing.T) {
 	}
 }
 
+func TestAddCountOverflow(t *testing.T) {
+	for _, s := range []Histogram{NewDenseSet(), NewConcurrentSet()} {
+		s.AddCount(one, math.MaxUint32-10)
+		s.AddCount(one, 100)
+		if got := s.Count(one.Detune()); got != math.MaxUint32 {
+			t.Errorf("%T: count should stop at %d, not %d", s, uint32(math.MaxUint32), got)
+		}
+
+		s.AddCount(two, -5)
+		if s.Len() != 1 {
+			t.Errorf("%T: negative counts should be ignored", s)
+		}
+	}
+
+	// frames shown for the longest GIF delay, with more than 2^32 / 65535
+	// sampled pixels of one color
+	img := image.NewNRGBA(image.Rect(0, 0, 520, 520))
+	for i := range img.Pix {
+		img.Pix[i] = 200
+	}
+	r := AnalyzeFrames([]Frame{{img, 65535 * 10 * time.Millisecond}}, &Options{KeepHistograms: true})
+	if got := r.Histogram.Count(RGB{200, 200, 200}.Detune()); got != math.MaxUint32 {
+		t.Errorf("weighted count should stop at %d, not %d", uint32(math.MaxUint32), got)
+	}
+}
+
 func TestString(t *testing.T) {
 	s := NewCountedSet(10)
 
@@ -122,3 +169,236 @@ func TestString(t *testing.T) {
 		t.Errorf("String conversion should be %s, not %s", answer, str)
 	}
 }
+
+func TestDenseSet(t *testing.T) {
+	d := NewDenseSet()
+	m := NewCountedSet(10)
+
+	for _, p := range []pixel{{0.2, 0.4, 0.6, 1}, {0.2, 0.4, 0.6, 1}, {1, 1, 1, 1}, {0, 0, 0, 1}} {
+		d.AddPixel(p)
+		m.AddPixel(p)
+	}
+
+	if d.Len() != m.Len() {
+		t.Errorf("Len should be %d, not %d", m.Len(), d.Len())
+	}
+
+	m.Range(func(color RGB, cnt int) {
+		if d.Count(color) != cnt {
+			t.Errorf("Count(%v) should be %d, not %d", color, cnt, d.Count(color))
+		}
+	})
+
+	entries := d.SortedSet()
+	if str := entries[0].String(); str != "306498: 2" {
+		t.Errorf("String conversion should be 306498: 2, not %s", str)
+	}
+}
+
+func benchmarkPixels() []pixel {
+	img := testImage(500, 500)
+	p := newPixelGetter(img)
+	pixels := make([]pixel, 0, 500*500)
+	for y := 0; y < 500; y++ {
+		for x := 0; x < 500; x++ {
+			pixels = append(pixels, p.getPixel(x, y))
+		}
+	}
+	return pixels
+}
+
+func benchmarkAddPixel(b *testing.B, newSet func() Histogram) {
+	pixels := benchmarkPixels()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := newSet()
+		for _, p := range pixels {
+			s.AddPixel(p)
+		}
+	}
+}
+
+func BenchmarkCountedSetAddPixel(b *testing.B) {
+	benchmarkAddPixel(b, func() Histogram { return NewCountedSet(10000) })
+}
+
+func BenchmarkDenseSetAddPixel(b *testing.B) {
+	benchmarkAddPixel(b, func() Histogram { return NewDenseSet() })
+}
+
+func BenchmarkCountedSetSortedSet(b *testing.B) {
+	s := NewCountedSet(10000)
+	for _, p := range benchmarkPixels() {
+		s.AddPixel(p)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.SortedSet()
+	}
+}
+
+func BenchmarkDenseSetSortedSet(b *testing.B) {
+	s := NewDenseSet()
+	for _, p := range benchmarkPixels() {
+		s.AddPixel(p)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.SortedSet()
+	}
+}
+
+func TestEntryIterator(t *testing.T) {
+	s := NewCountedSet(10)
+	s.AddCount(zero, 5)
+	s.AddCount(one, 1)
+	s.AddCount(two, 3)
+
+	it := NewEntryIterator(s)
+	for _, want := range []RGB{zero, two, one} {
+		e, ok := it.Next()
+		if !ok || e.Color != want {
+			t.Errorf("Next should return %v, not %v (%v)", want, e.Color, ok)
+		}
+	}
+
+	if _, ok := it.Next(); ok {
+		t.Error("Next should be done after 3 entries")
+	}
+}
+
+func TestTopK(t *testing.T) {
+	s := highColorSet()
+	sorted := s.SortedSet()
+
+	for _, k := range []int{0, 1, 3, 100, len(sorted) + 10} {
+		top := TopK(s, k)
+		want := k
+		if want > len(sorted) {
+			want = len(sorted)
+		}
+		if len(top) != want {
+			t.Errorf("TopK(%d) returned %d entries", k, len(top))
+			continue
+		}
+		for i, e := range top {
+			if e.Count != sorted[i].Count {
+				t.Errorf("TopK(%d)[%d] count should be %d, not %d", k, i, sorted[i].Count, e.Count)
+				break
+			}
+		}
+	}
+}
+
+// highColorSet counts a noisy, photograph like image with many colors.
+func highColorSet() Histogram {
+	r := rand.New(rand.NewSource(1))
+	s := NewDenseSet()
+	for i := 0; i < 500*500; i++ {
+		// cluster around a few hues the way a photograph would
+		base := float32(i%7) / 7
+		s.AddPixel(pixel{base, r.Float32(), base * r.Float32(), 1})
+	}
+	return s
+}
+
+func BenchmarkSortedSetFirst3(b *testing.B) {
+	s := highColorSet()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = s.SortedSet()[:3]
+	}
+}
+
+func BenchmarkEntryIteratorFirst3(b *testing.B) {
+	s := highColorSet()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		it := NewEntryIterator(s)
+		it.Next()
+		it.Next()
+		it.Next()
+	}
+}
+
+func BenchmarkTopK3(b *testing.B) {
+	s := highColorSet()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		TopK(s, 3)
+	}
+}
+
+func TestRGBColor(t *testing.T) {
+	for k := 0; k < 256; k++ {
+		c := RGB{uint8(k), uint8(255 - k), uint8(k / 2)}
+		if c.Color().RGB() != c {
+			t.Errorf("%v does not survive conversion to Color and back", c)
+		}
+	}
+
+	for _, tt := range []struct {
+		c    Color
+		want RGB
+	}{
+		{Color{0.2, 0.4, 0.6, true}, RGB{51, 102, 153}},
+		{Color{0.999, 0.001, 0.5, true}, RGB{255, 0, 128}},
+		{Color{199.7 / 255, 28.4 / 255, 0.49 / 255, true}, RGB{200, 28, 0}},
+		{Color{1.2, -0.1, 0.5 / 255, true}, RGB{255, 0, 1}},
+	} {
+		if got := tt.c.RGB(); got != tt.want {
+			t.Errorf("%v.RGB() should be %v, not %v", tt.c, tt.want, got)
+		}
+		if got := tt.c.String(); got != tt.want.String() {
+			t.Errorf("%v.String() should be %s, not %s", tt.c, tt.want, got)
+		}
+	}
+
+	if d := (RGB{0x33, 0x66, 0x99}).Detune(); d.String() != "#306498" {
+		t.Errorf("Detune should be #306498, not %s", d)
+	}
+}
+
+func TestConcurrentSet(t *testing.T) {
+	pixels := benchmarkPixels()
+	want := NewDenseSet()
+	for _, p := range pixels {
+		want.AddPixel(p)
+	}
+
+	s := NewConcurrentSet()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g; i < len(pixels); i += 8 {
+				s.AddPixel(pixels[i])
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if !sameCounts(s, want) || !sameCounts(s.Snapshot(), want) {
+		t.Error("concurrent counts differ from single threaded counts")
+	}
+}
+
+func BenchmarkConcurrentSetAddPixel(b *testing.B) {
+	pixels := benchmarkPixels()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := NewConcurrentSet()
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for j := g; j < len(pixels); j += 4 {
+					s.AddPixel(pixels[j])
+				}
+			}(g)
+		}
+		wg.Wait()
+	}
+}
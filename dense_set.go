@@ -0,0 +1,212 @@
+package colorart
+
+import (
+	"image/color"
+	"math"
+	"sort"
+	"sync"
+)
+
+const (
+	// bits kept of each color component after detuning
+	denseBits = 8 - colorShifter
+
+	// number of distinct colors after detuning
+	denseSize = 1 << (3 * denseBits)
+
+	// largest table worth allocating; 2^18 uint32s is 1MB
+	maxDenseSize = 1 << 18
+)
+
+// Histogram counts colors.  CountedSet, DenseSet and ConcurrentSet
+// implement it.
+type Histogram interface {
+	// AddPixel detunes the pixel and counts its color.
+	AddPixel(p pixel)
+	// AddColor detunes c and counts it, the way a pixel of an image is.
+	AddColor(c color.Color)
+	// AddCount adds count to color.  Counts that are not positive are
+	// ignored.
+	AddCount(color RGB, count int)
+	// Count returns the number of times color has been counted.
+	Count(color RGB) int
+	// Len returns the number of distinct colors.
+	Len() int
+	// Range calls fn for every color with a non zero count.
+	Range(fn func(color RGB, count int))
+	// SortedSet returns the entries ordered from greatest count to least,
+	// then by color.
+	SortedSet() []CountedEntry
+}
+
+// NewHistogram returns the fastest Histogram for the current detuning.
+// Colors are counted in a DenseSet when the number of possible detuned
+// colors is small enough, otherwise in a CountedSet of the given size.
+func NewHistogram(size int) Histogram {
+	if denseSize <= maxDenseSize {
+		return NewDenseSet()
+	}
+	return NewCountedSet(size)
+}
+
+// DenseSet counts colors in a fixed size table indexed by the packed,
+// detuned color, avoiding the hashing and growth of a CountedSet.  Only
+// the detuned bits of a color are kept, so colors that detune to the same
+// value share a count.  The set is not thread safe; see ConcurrentSet.
+type DenseSet struct {
+	counts []uint32
+	n      int
+}
+
+var densePool = sync.Pool{
+	New: func() interface{} { return make([]uint32, denseSize) },
+}
+
+// NewDenseSet creates an empty DenseSet.
+func NewDenseSet() *DenseSet {
+	return &DenseSet{counts: make([]uint32, denseSize)}
+}
+
+// newPooledDenseSet returns a DenseSet backed by a recycled table, which
+// must be handed back with release.
+func newPooledDenseSet() *DenseSet {
+	counts := densePool.Get().([]uint32)
+	for i := range counts {
+		counts[i] = 0
+	}
+	return &DenseSet{counts: counts}
+}
+
+func (s *DenseSet) release() {
+	densePool.Put(s.counts)
+	s.counts = nil
+	s.n = 0
+}
+
+func denseIndex(color RGB) int {
+	const b = colorShifter
+	return int(color[0]>>b)<<(2*denseBits) | int(color[1]>>b)<<denseBits | int(color[2]>>b)
+}
+
+func denseColor(i int) RGB {
+	const b = colorShifter
+	const mask = 1<<denseBits - 1
+	return RGB{uint8(i>>(2*denseBits)) << b, uint8(i>>denseBits&mask) << b, uint8(i&mask) << b}
+}
+
+// AddPixel converts pixel to a detuned RGB key and counts unique colors
+func (s *DenseSet) AddPixel(p pixel) {
+	b := uint8(colorShifter)
+	ri := uint8(maxComponent*p.R) >> b
+	gi := uint8(maxComponent*p.G) >> b
+	bi := uint8(maxComponent*p.B) >> b
+
+	i := int(ri)<<(2*denseBits) | int(gi)<<denseBits | int(bi)
+	if s.counts[i] == 0 {
+		s.n++
+	}
+	s.counts[i]++
+}
+
+// colorPixel converts c the way pixelGetter reads pixels of its type, so
+// colors added one at a time are counted like those of an image.
+func colorPixel(c color.Color) pixel {
+	switch c := c.(type) {
+	case color.NRGBA:
+		return pixel{float32(c.R) * qf8, float32(c.G) * qf8, float32(c.B) * qf8, float32(c.A) * qf8}
+	case color.RGBA:
+		if c.A == 255 {
+			return pixel{float32(c.R) * qf8, float32(c.G) * qf8, float32(c.B) * qf8, 1}
+		}
+	}
+	return pixelclr(c)
+}
+
+// AddColor detunes c and counts it, the way a pixel of an image is.
+func (s *DenseSet) AddColor(c color.Color) {
+	s.AddPixel(colorPixel(c))
+}
+
+// AddCount adds count to color.  Counts stop at math.MaxUint32 rather
+// than wrapping.
+func (s *DenseSet) AddCount(color RGB, count int) {
+	if count <= 0 {
+		return
+	}
+	i := denseIndex(color)
+	if s.counts[i] == 0 {
+		s.n++
+	}
+	s.counts[i] = addSaturating(s.counts[i], count)
+}
+
+// addSaturating returns c + count, or math.MaxUint32 if that is larger.
+func addSaturating(c uint32, count int) uint32 {
+	if uint64(count) >= math.MaxUint32-uint64(c) {
+		return math.MaxUint32
+	}
+	return c + uint32(count)
+}
+
+// Count returns the number of times color has been counted.
+func (s *DenseSet) Count(color RGB) int {
+	return int(s.counts[denseIndex(color)])
+}
+
+// Len returns the number of distinct colors.
+func (s *DenseSet) Len() int {
+	return s.n
+}
+
+// Merge other dense set into this one.
+func (s *DenseSet) Merge(o *DenseSet) {
+	n := 0
+	for i, cnt := range o.counts {
+		s.counts[i] = addSaturating(s.counts[i], int(cnt))
+		if s.counts[i] != 0 {
+			n++
+		}
+	}
+	s.n = n
+}
+
+// Range calls fn for every color with a non zero count.
+func (s *DenseSet) Range(fn func(color RGB, count int)) {
+	for i, cnt := range s.counts {
+		if cnt != 0 {
+			fn(denseColor(i), int(cnt))
+		}
+	}
+}
+
+// SortedSet returns the entries (Color, Count) ordered from greatest count to least.
+// Entries with the same count are ordered by color.
+func (s *DenseSet) SortedSet() []CountedEntry {
+	list := make([]CountedEntry, 0, s.n)
+	s.Range(func(color RGB, cnt int) {
+		list = append(list, CountedEntry{color, cnt})
+	})
+
+	sort.Sort(ByCount(list))
+	return list
+}
+
+// newPartHistogram returns a Histogram for counting part of an image.
+// parallelize returns recycled tables to the pool once merged.
+func newPartHistogram() Histogram {
+	if denseSize <= maxDenseSize {
+		return newPooledDenseSet()
+	}
+	return NewCountedSet(10000)
+}
+
+// mergeHistogram adds the counts of src to dst.
+func mergeHistogram(dst, src Histogram) {
+	if d, ok := dst.(*DenseSet); ok {
+		if s, ok := src.(*DenseSet); ok {
+			d.Merge(s)
+			return
+		}
+	}
+	src.Range(dst.AddCount)
+}
@@ -0,0 +1,256 @@
+// Package ebook extracts cover images from EPUB e-books and CBZ comic
+// archives.
+package ebook
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"image"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrNoCover is returned when no cover image can be found.
+	ErrNoCover = errors.New("ebook: no cover image")
+
+	// ErrFormat is returned for unrecognized or malformed archives.
+	ErrFormat = errors.New("ebook: unrecognized or malformed archive")
+)
+
+// largest cover image that ReadCoverFile will read
+const maxCoverSize = 64 << 20
+
+// imageExtensions are the file extensions CBZCover considers images.
+var imageExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+}
+
+// DecodeCoverFile decodes the cover of the named .epub or .cbz file.  The
+// image format must be registered with the image package.
+func DecodeCoverFile(filename string) (image.Image, error) {
+	z, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer z.Close()
+
+	if strings.EqualFold(filepath.Ext(filename), ".epub") {
+		return EPUBCover(&z.Reader)
+	}
+	return CBZCover(&z.Reader)
+}
+
+// ReadCoverFile returns the encoded cover image of the named .epub or .cbz
+// file, so it can be checked before it is decoded.
+func ReadCoverFile(filename string) ([]byte, error) {
+	z, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer z.Close()
+
+	var f *zip.File
+	if strings.EqualFold(filepath.Ext(filename), ".epub") {
+		f, err = epubCoverFile(&z.Reader)
+	} else {
+		f, err = cbzCoverFile(&z.Reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if f.UncompressedSize64 > maxCoverSize {
+		return nil, ErrFormat
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	return ioutil.ReadAll(io.LimitReader(r, maxCoverSize))
+}
+
+// CBZCover decodes the first image, by file name, of a comic archive.
+func CBZCover(z *zip.Reader) (image.Image, error) {
+	f, err := cbzCoverFile(z)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFile(f)
+}
+
+func cbzCoverFile(z *zip.Reader) (*zip.File, error) {
+	var names []string
+	files := make(map[string]*zip.File)
+
+	for _, f := range z.File {
+		name := f.Name
+		base := path.Base(name)
+		if f.FileInfo().IsDir() || strings.HasPrefix(base, ".") || strings.HasPrefix(name, "__MACOSX/") {
+			continue
+		}
+		if !imageExtensions[strings.ToLower(path.Ext(name))] {
+			continue
+		}
+		names = append(names, name)
+		files[name] = f
+	}
+
+	if len(names) == 0 {
+		return nil, ErrNoCover
+	}
+
+	sort.Strings(names)
+	return files[names[0]], nil
+}
+
+type container struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type opfPackage struct {
+	Metadata struct {
+		Meta []struct {
+			Name    string `xml:"name,attr"`
+			Content string `xml:"content,attr"`
+		} `xml:"meta"`
+	} `xml:"metadata"`
+	Manifest struct {
+		Items []opfItem `xml:"item"`
+	} `xml:"manifest"`
+}
+
+type opfItem struct {
+	ID         string `xml:"id,attr"`
+	Href       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
+}
+
+// EPUBCover decodes the cover image named by the package (OPF) document
+// of an EPUB: the EPUB 3 cover-image item, else the item named by the
+// EPUB 2 cover meta, else an image item with "cover" in its id or name.
+func EPUBCover(z *zip.Reader) (image.Image, error) {
+	f, err := epubCoverFile(z)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFile(f)
+}
+
+func epubCoverFile(z *zip.Reader) (*zip.File, error) {
+	files := make(map[string]*zip.File)
+	for _, f := range z.File {
+		files[f.Name] = f
+	}
+
+	var c container
+	if err := decodeXML(files["META-INF/container.xml"], &c); err != nil {
+		return nil, err
+	}
+	if len(c.Rootfiles) == 0 {
+		return nil, ErrFormat
+	}
+
+	opfPath := c.Rootfiles[0].FullPath
+	var pkg opfPackage
+	if err := decodeXML(files[opfPath], &pkg); err != nil {
+		return nil, err
+	}
+
+	item := findCover(&pkg)
+	if item == nil {
+		return nil, ErrNoCover
+	}
+
+	href, err := url.PathUnescape(item.Href)
+	if err != nil {
+		href = item.Href
+	}
+
+	f := files[path.Join(path.Dir(opfPath), href)]
+	if f == nil {
+		return nil, ErrNoCover
+	}
+
+	return f, nil
+}
+
+func findCover(pkg *opfPackage) *opfItem {
+	items := pkg.Manifest.Items
+
+	for i := range items {
+		for _, p := range strings.Fields(items[i].Properties) {
+			if p == "cover-image" {
+				return &items[i]
+			}
+		}
+	}
+
+	for _, m := range pkg.Metadata.Meta {
+		if m.Name != "cover" {
+			continue
+		}
+		for i := range items {
+			if items[i].ID == m.Content && strings.HasPrefix(items[i].MediaType, "image/") {
+				return &items[i]
+			}
+		}
+	}
+
+	for i := range items {
+		name := strings.ToLower(items[i].ID + " " + path.Base(items[i].Href))
+		if strings.HasPrefix(items[i].MediaType, "image/") && strings.Contains(name, "cover") {
+			return &items[i]
+		}
+	}
+
+	return nil
+}
+
+func decodeXML(f *zip.File, v interface{}) error {
+	if f == nil {
+		return ErrFormat
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+
+	if err := xml.NewDecoder(r).Decode(v); err != nil && err != io.EOF {
+		return ErrFormat
+	}
+	return nil
+}
+
+func decodeFile(f *zip.File) (image.Image, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	img, _, err := image.Decode(r)
+	return img, err
+}
@@ -0,0 +1,105 @@
+package ebook
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func testPNG(t *testing.T, w int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, w, 2))
+	img.SetNRGBA(0, 0, color.NRGBA{10, 20, 30, 255})
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testZip(t *testing.T, files map[string][]byte) *zip.Reader {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return z
+}
+
+const containerXML = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`
+
+func TestEPUBCover(t *testing.T) {
+	for name, opf := range map[string]string{
+		"epub3": `<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <manifest>
+    <item id="other" href="images/other.png" media-type="image/png"/>
+    <item id="c" href="images/front%20cover.png" media-type="image/png" properties="cover-image"/>
+  </manifest>
+</package>`,
+		"epub2": `<package xmlns="http://www.idpf.org/2007/opf" version="2.0">
+  <metadata><meta name="cover" content="c"/></metadata>
+  <manifest>
+    <item id="other" href="images/other.png" media-type="image/png"/>
+    <item id="c" href="images/front%20cover.png" media-type="image/png"/>
+  </manifest>
+</package>`,
+	} {
+		z := testZip(t, map[string][]byte{
+			"mimetype":                     []byte("application/epub+zip"),
+			"META-INF/container.xml":       []byte(containerXML),
+			"OEBPS/content.opf":            []byte(opf),
+			"OEBPS/images/other.png":       testPNG(t, 2),
+			"OEBPS/images/front cover.png": testPNG(t, 3),
+		})
+
+		img, err := EPUBCover(z)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if img.Bounds().Dx() != 3 {
+			t.Errorf("%s: decoded the wrong image", name)
+		}
+	}
+}
+
+func TestCBZCover(t *testing.T) {
+	z := testZip(t, map[string][]byte{
+		"__MACOSX/._001.png": []byte("junk"),
+		"comic/002.png":      testPNG(t, 2),
+		"comic/001.png":      testPNG(t, 3),
+		"comic/info.txt":     []byte("not an image"),
+	})
+
+	img, err := CBZCover(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 3 {
+		t.Error("cover should be the first image by name")
+	}
+
+	if _, err := CBZCover(testZip(t, map[string][]byte{"a.txt": nil})); err != ErrNoCover {
+		t.Errorf("archive without images should fail with ErrNoCover, not %v", err)
+	}
+}
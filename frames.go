@@ -0,0 +1,70 @@
+package colorart
+
+import (
+	"image"
+	"time"
+)
+
+// Frame is one frame of an animation.
+type Frame struct {
+	Image image.Image
+	Delay time.Duration
+}
+
+// frameWeight is how many times the colors of a frame are counted when
+// frames are combined: once per 10ms (the resolution of GIF delays) it is
+// shown, and at least once.
+func frameWeight(f Frame) int {
+	w := int(f.Delay / (10 * time.Millisecond))
+	if w < 1 {
+		w = 1
+	}
+	return w
+}
+
+// AnalyzeFrames analyzes every frame of an animation as if they were one
+// image, weighting the colors of each frame by how long it is shown.
+// Frames are counted one at a time, each with opts.
+func AnalyzeFrames(frames []Frame, opts *Options) Result {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+
+	edgeColors := NewCountedSet(500)
+	imageColors := NewHistogram(10000)
+
+	for _, f := range frames {
+		c := &colorArt{img: newPixelGetter(f.Image), opts: o}
+		w := frameWeight(f)
+
+		c.countEdgeColors().Range(func(color RGB, cnt int) {
+			edgeColors.AddCount(color, cnt*w)
+		})
+
+		counted := c.countImageColors()
+		counted.Range(func(color RGB, cnt int) {
+			imageColors.AddCount(color, cnt*w)
+		})
+		if d, ok := counted.(*DenseSet); ok {
+			d.release()
+		}
+	}
+
+	r := selectColors(edgeColors, imageColors)
+	if o.KeepHistograms {
+		r.Histogram = imageColors
+		r.EdgeHistogram = edgeColors
+	}
+
+	return r
+}
+
+// AnalyzeEachFrame analyzes every frame of an animation on its own.
+func AnalyzeEachFrame(frames []Frame, opts *Options) []Result {
+	results := make([]Result, len(frames))
+	for i, f := range frames {
+		results[i] = AnalyzeResult(f.Image, opts)
+	}
+	return results
+}
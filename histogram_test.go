@@ -0,0 +1,57 @@
+package colorart_test
+
+import (
+	"image"
+	"image/color"
+	"sync"
+	"testing"
+
+	"github.com/sspencer/colorart"
+)
+
+// TestAddColor feeds pixels from outside the package, as a pipeline
+// decoding its own images would, and checks they are counted the same as
+// the pixels of an image.
+func TestAddColor(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x * 6), uint8(y * 8), 90, 255})
+		}
+	}
+	want := colorart.AnalyzeResult(img, &colorart.Options{KeepHistograms: true})
+
+	concurrent := colorart.NewConcurrentSet()
+	sets := []colorart.Histogram{colorart.NewDenseSet(), colorart.NewCountedSet(0), concurrent}
+	for _, s := range sets[:2] {
+		// every other pixel of every other row, as the analysis samples
+		for y := 0; y < 30; y += 2 {
+			for x := 0; x < 40; x += 2 {
+				s.AddColor(img.At(x, y))
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for y := 0; y < 30; y += 2 {
+		wg.Add(1)
+		go func(y int) {
+			defer wg.Done()
+			for x := 0; x < 40; x += 2 {
+				concurrent.AddColor(img.At(x, y))
+			}
+		}(y)
+	}
+	wg.Wait()
+
+	for _, s := range sets {
+		if s.Len() != want.Histogram.Len() {
+			t.Errorf("%T: counted %d colors, want %d", s, s.Len(), want.Histogram.Len())
+		}
+		want.Histogram.Range(func(c colorart.RGB, count int) {
+			if got := s.Count(c); got != count {
+				t.Errorf("%T: %s counted %d times, want %d", s, c, got, count)
+			}
+		})
+	}
+}
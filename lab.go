@@ -0,0 +1,78 @@
+package colorart
+
+import "math"
+
+// D65 reference white
+const (
+	whiteX = 0.95047
+	whiteY = 1.0
+	whiteZ = 1.08883
+)
+
+// Lab converts the (sRGB) color to CIE L*a*b*, where L is 0-100.
+func (c Color) Lab() (l, a, b float64) {
+	r, g, bl := linearize(c.R), linearize(c.G), linearize(c.B)
+
+	x := (0.4124564*r + 0.3575761*g + 0.1804375*bl) / whiteX
+	y := (0.2126729*r + 0.7151522*g + 0.0721750*bl) / whiteY
+	z := (0.0193339*r + 0.1191920*g + 0.9503041*bl) / whiteZ
+
+	fx, fy, fz := labF(x), labF(y), labF(z)
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+// LabToColor converts CIE L*a*b* to an sRGB Color, clipping colors outside
+// of the sRGB gamut.
+func LabToColor(l, a, b float64) Color {
+	fy := (l + 16) / 116
+	fx := fy + a/500
+	fz := fy - b/200
+
+	x := labFInv(fx) * whiteX
+	y := labFInv(fy) * whiteY
+	z := labFInv(fz) * whiteZ
+
+	r := 3.2404542*x - 1.5371385*y - 0.4985314*z
+	g := -0.9692660*x + 1.8760108*y + 0.0415560*z
+	bl := 0.0556434*x - 0.2040259*y + 1.0572252*z
+
+	return Color{delinearize(r), delinearize(g), delinearize(bl), true}
+}
+
+// DeltaE returns the CIE76 color difference between two colors.  A
+// difference of about 2.3 is just noticeable.
+func (c Color) DeltaE(d Color) float64 {
+	l1, a1, b1 := c.Lab()
+	l2, a2, b2 := d.Lab()
+	return math.Sqrt((l1-l2)*(l1-l2) + (a1-a2)*(a1-a2) + (b1-b2)*(b1-b2))
+}
+
+func linearize(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func delinearize(v float64) float64 {
+	if v <= 0.0031308 {
+		v *= 12.92
+	} else {
+		v = 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+	return math.Max(0, math.Min(1, v))
+}
+
+func labF(t float64) float64 {
+	if t > 216.0/24389.0 {
+		return math.Cbrt(t)
+	}
+	return (24389.0/27.0*t + 16) / 116
+}
+
+func labFInv(t float64) float64 {
+	if t3 := t * t * t; t3 > 216.0/24389.0 {
+		return t3
+	}
+	return (116*t - 16) * 27.0 / 24389.0
+}
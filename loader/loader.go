@@ -0,0 +1,191 @@
+// Package loader opens the files the colorart commands accept as images:
+// GIF, JPEG and PNG images, the cover art embedded in audio files, and the
+// covers of EPUB e-books and CBZ comics.
+//
+// Images are only decoded after their header has been checked against a
+// pixel budget, so a small file can't claim a huge image and exhaust
+// memory.
+package loader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sspencer/colorart/audio"
+	"github.com/sspencer/colorart/ebook"
+)
+
+// DefaultMaxPixels is the pixel budget, in width times height, used when
+// none is given.
+const DefaultMaxPixels = 50 * 1000 * 1000
+
+// ErrTooLarge is returned, with the image size, for images larger than the
+// pixel budget.
+var ErrTooLarge = errors.New("loader: image is too large")
+
+// ImageExtensions are the file extensions decoded as images.
+var ImageExtensions = map[string]bool{
+	".gif":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".apng": true,
+}
+
+// AudioExtensions are the file extensions read as audio files.
+var AudioExtensions = map[string]bool{
+	".mp3":  true,
+	".flac": true,
+	".m4a":  true,
+	".m4b":  true,
+	".mp4":  true,
+	".ogg":  true,
+	".oga":  true,
+	".opus": true,
+}
+
+// BookExtensions are the file extensions read as e-books or comics.
+var BookExtensions = map[string]bool{
+	".epub": true,
+	".cbz":  true,
+}
+
+// IsImage reports whether filename has an image file extension.
+func IsImage(filename string) bool {
+	return ImageExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// IsAudio reports whether filename has an audio file extension.
+func IsAudio(filename string) bool {
+	return AudioExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// IsBook reports whether filename has an e-book or comic extension.
+func IsBook(filename string) bool {
+	return BookExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// IsContainer reports whether filename holds a cover rather than being an
+// image itself.
+func IsContainer(filename string) bool {
+	return IsAudio(filename) || IsBook(filename)
+}
+
+// IsSupported reports whether Load can read filename by its extension.
+func IsSupported(filename string) bool {
+	return IsImage(filename) || IsContainer(filename)
+}
+
+// Load decodes the image in filename, or the cover of an audio file,
+// e-book or comic, within DefaultMaxPixels.
+func Load(filename string) (image.Image, error) {
+	return LoadLimit(filename, DefaultMaxPixels)
+}
+
+// LoadLimit is like Load with a budget of maxPixels.  A maxPixels <= 0
+// uses DefaultMaxPixels.
+func LoadLimit(filename string, maxPixels int64) (image.Image, error) {
+	var r io.Reader
+	if IsContainer(filename) {
+		data, err := readCover(filename)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+	} else {
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		defer file.Close()
+		r = file
+	}
+
+	img, _, err := Decode(r, maxPixels)
+	return img, err
+}
+
+// readCover returns the encoded cover of an audio file, e-book or comic.
+func readCover(filename string) ([]byte, error) {
+	if IsBook(filename) {
+		return ebook.ReadCoverFile(filename)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	pictures, err := audio.ReadPictures(file)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := audio.Cover(pictures)
+	if err != nil {
+		return nil, err
+	}
+	return p.Data, nil
+}
+
+// Decode decodes an image like image.Decode, unless image.DecodeConfig
+// says it has more than maxPixels pixels, when the error wraps ErrTooLarge.
+// A maxPixels <= 0 uses DefaultMaxPixels.
+func Decode(r io.Reader, maxPixels int64) (image.Image, string, error) {
+	// keep what DecodeConfig reads to decode it again
+	var header bytes.Buffer
+	if _, _, err := DecodeConfig(io.TeeReader(r, &header), maxPixels); err != nil {
+		return nil, "", err
+	}
+
+	return image.Decode(io.MultiReader(&header, r))
+}
+
+// DecodeConfig returns the size and format of an image like
+// image.DecodeConfig, and an error wrapping ErrTooLarge if it has more than
+// maxPixels pixels.  A maxPixels <= 0 uses DefaultMaxPixels.
+func DecodeConfig(r io.Reader, maxPixels int64) (image.Config, string, error) {
+	cfg, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return cfg, format, err
+	}
+
+	return cfg, format, CheckSize(cfg.Width, cfg.Height, 1, maxPixels)
+}
+
+// CheckSize returns an error wrapping ErrTooLarge if frames images of
+// width x height pixels have more than maxPixels pixels together, such as
+// the frames of an animation, each drawn on a canvas the size of the
+// image.  A maxPixels <= 0 uses DefaultMaxPixels.
+func CheckSize(width, height, frames int, maxPixels int64) error {
+	if maxPixels <= 0 {
+		maxPixels = DefaultMaxPixels
+	}
+
+	// divide rather than multiply, so huge sizes can't overflow
+	tooLarge := width < 0 || height < 0 || frames < 0
+	if !tooLarge && width > 0 && height > 0 && frames > 0 {
+		tooLarge = int64(height) > maxPixels/int64(width) ||
+			int64(frames) > maxPixels/(int64(width)*int64(height))
+	}
+	if !tooLarge {
+		return nil
+	}
+
+	if frames == 1 {
+		return fmt.Errorf("%w: %dx%d is more than %d pixels", ErrTooLarge, width, height, maxPixels)
+	}
+	return fmt.Errorf("%w: %d frames of %dx%d are more than %d pixels", ErrTooLarge, frames, width, height, maxPixels)
+}
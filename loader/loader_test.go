@@ -0,0 +1,75 @@
+package loader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"testing"
+)
+
+// bomb returns a tiny PNG whose header claims it is w x h.
+func bomb(t *testing.T, w, h uint32) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+
+	// signature, then length, "IHDR", width, height ... and the CRC
+	b := buf.Bytes()
+	binary.BigEndian.PutUint32(b[16:], w)
+	binary.BigEndian.PutUint32(b[20:], h)
+	binary.BigEndian.PutUint32(b[29:], crc32.ChecksumIEEE(b[12:29]))
+	return b
+}
+
+func TestDecodeTooLarge(t *testing.T) {
+	_, _, err := Decode(bytes.NewReader(bomb(t, 50000, 50000)), 0)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("50000x50000 image should be too large, got %v", err)
+	}
+
+	_, _, err = Decode(bytes.NewReader(bomb(t, 100, 100)), 100*100-1)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("100x100 image should be over a budget of 9999, got %v", err)
+	}
+}
+
+func TestCheckSize(t *testing.T) {
+	for _, tt := range []struct {
+		w, h, frames int
+		max          int64
+		ok           bool
+	}{
+		{100, 100, 1, 10000, true},
+		{100, 100, 1, 9999, false},
+		{100, 100, 3, 30000, true},
+		{100, 100, 3, 29999, false},
+		{0, 100, 1000, 1, true},
+		{-1, 100, 1, 0, false},
+		{1 << 40, 1 << 40, 1 << 40, 0, false},
+		{1, 1, 1 << 62, 0, false},
+	} {
+		err := CheckSize(tt.w, tt.h, tt.frames, tt.max)
+		if tt.ok && err != nil || !tt.ok && !errors.Is(err, ErrTooLarge) {
+			t.Errorf("%d frames of %dx%d within %d: got %v", tt.frames, tt.w, tt.h, tt.max, err)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 30, 20))); err != nil {
+		t.Fatal(err)
+	}
+
+	img, format, err := Decode(&buf, 30*20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "png" || img.Bounds() != image.Rect(0, 0, 30, 20) {
+		t.Errorf("decoded a %s %v, want a png (0,0)-(30,20)", format, img.Bounds())
+	}
+}
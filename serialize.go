@@ -0,0 +1,172 @@
+package colorart
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+)
+
+// histogramMagic starts the binary encoding of a histogram; the last byte
+// is the format version.
+const histogramMagic = "CAH\x01"
+
+// ErrHistogramFormat is returned when decoding malformed histogram data.
+var ErrHistogramFormat = errors.New("colorart: invalid histogram data")
+
+// AnalyzeHistogram chooses the colors of an image from previously counted
+// (and possibly stored) histograms, such as Result.EdgeHistogram and
+// Result.Histogram.  The result does not include the histograms.
+func AnalyzeHistogram(edgeColors, imageColors Histogram) Result {
+	return selectColors(edgeColors, imageColors)
+}
+
+// MarshalText encodes the key as a HTML hex code (#9a45bc).
+func (k RGB) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText decodes a HTML hex code (#9a45bc).
+func (k *RGB) UnmarshalText(text []byte) error {
+	if len(text) != 7 || text[0] != '#' {
+		return ErrHistogramFormat
+	}
+	if _, err := hex.Decode(k[:], text[1:]); err != nil {
+		return ErrHistogramFormat
+	}
+	return nil
+}
+
+// MarshalText encodes the color as a HTML hex code (#9a45bc).
+func (c Color) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText decodes a HTML hex code (#9a45bc).
+func (c *Color) UnmarshalText(text []byte) error {
+	var k RGB
+	if err := k.UnmarshalText(text); err != nil {
+		return err
+	}
+	*c = k.Color()
+	return nil
+}
+
+// marshalHistogram encodes the entries of s in ascending color order:
+// histogramMagic, the number of entries, then 3 color bytes and a
+// uvarint count per entry.
+func marshalHistogram(s Histogram) []byte {
+	list := make([]CountedEntry, 0, s.Len())
+	s.Range(func(color RGB, cnt int) {
+		list = append(list, CountedEntry{color, cnt})
+	})
+	sort.Slice(list, func(i, j int) bool { return colorBefore(list[i].Color, list[j].Color) })
+
+	buf := make([]byte, 0, len(histogramMagic)+binary.MaxVarintLen64+len(list)*6)
+	buf = append(buf, histogramMagic...)
+	buf = binary.AppendUvarint(buf, uint64(len(list)))
+	for _, e := range list {
+		buf = append(buf, e.Color[:]...)
+		buf = binary.AppendUvarint(buf, uint64(e.Count))
+	}
+	return buf
+}
+
+// unmarshalHistogram decodes data written by marshalHistogram, calling
+// add for every entry.
+func unmarshalHistogram(data []byte, add func(color RGB, count int)) error {
+	if len(data) < len(histogramMagic) || string(data[:len(histogramMagic)]) != histogramMagic {
+		return ErrHistogramFormat
+	}
+	data = data[len(histogramMagic):]
+
+	n, k := binary.Uvarint(data)
+	if k <= 0 {
+		return ErrHistogramFormat
+	}
+	data = data[k:]
+
+	for i := uint64(0); i < n; i++ {
+		if len(data) < 4 {
+			return ErrHistogramFormat
+		}
+		var color RGB
+		copy(color[:], data)
+		cnt, k := binary.Uvarint(data[3:])
+		if k <= 0 {
+			return ErrHistogramFormat
+		}
+		if cnt > math.MaxUint32 {
+			return ErrHistogramFormat
+		}
+		data = data[3+k:]
+		add(color, int(cnt))
+	}
+
+	if len(data) != 0 {
+		return ErrHistogramFormat
+	}
+	return nil
+}
+
+// MarshalBinary encodes the set.  The encoding is shared with DenseSet.
+// CountedSet needs no MarshalJSON: RGB keys encode as hex codes, so
+// json.Marshal writes an object such as {"#9a45bc": 12}.
+func (s CountedSet) MarshalBinary() ([]byte, error) {
+	return marshalHistogram(s), nil
+}
+
+// UnmarshalBinary replaces the set with the decoded data.
+func (s *CountedSet) UnmarshalBinary(data []byte) error {
+	set := NewCountedSet(1000)
+	if err := unmarshalHistogram(data, set.AddCount); err != nil {
+		return err
+	}
+	*s = set
+	return nil
+}
+
+// MarshalBinary encodes the set.  The encoding is shared with CountedSet.
+func (s *DenseSet) MarshalBinary() ([]byte, error) {
+	return marshalHistogram(s), nil
+}
+
+// UnmarshalBinary replaces the set with the decoded data.
+func (s *DenseSet) UnmarshalBinary(data []byte) error {
+	set := NewDenseSet()
+	if err := unmarshalHistogram(data, set.AddCount); err != nil {
+		return err
+	}
+	*s = *set
+	return nil
+}
+
+// MarshalJSON encodes the set the same way as a CountedSet.
+func (s *DenseSet) MarshalJSON() ([]byte, error) {
+	m := make(map[RGB]int, s.n)
+	s.Range(func(color RGB, cnt int) {
+		m[color] = cnt
+	})
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON replaces the set with an object of hex color keys and
+// counts ({"#9a45bc": 12}).
+func (s *DenseSet) UnmarshalJSON(data []byte) error {
+	var m map[RGB]int
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	set := NewDenseSet()
+	for color, cnt := range m {
+		if cnt < 0 || int64(cnt) > math.MaxUint32 {
+			return ErrHistogramFormat
+		}
+		set.AddCount(color, cnt)
+	}
+	*s = *set
+	return nil
+}
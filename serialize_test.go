@@ -0,0 +1,117 @@
+package colorart
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+)
+
+func TestHistogramBinary(t *testing.T) {
+	r := AnalyzeResult(testImage(60, 40), &Options{KeepHistograms: true})
+
+	data, err := r.Histogram.(*DenseSet).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// either backend can read the encoding
+	var dense DenseSet
+	if err := dense.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	var counted CountedSet
+	if err := counted.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !sameCounts(&dense, r.Histogram) || !sameCounts(counted, r.Histogram) {
+		t.Error("decoded histogram differs from the original")
+	}
+
+	edgeData, _ := r.EdgeHistogram.(CountedSet).MarshalBinary()
+	var edges CountedSet
+	if err := edges.UnmarshalBinary(edgeData); err != nil {
+		t.Fatal(err)
+	}
+
+	got := AnalyzeHistogram(edges, &dense)
+	if got.Background != r.Background || got.Primary != r.Primary || got.Secondary != r.Secondary || got.Detail != r.Detail {
+		t.Errorf("AnalyzeHistogram should match AnalyzeResult: %v, %v", got, r)
+	}
+
+	if err := dense.UnmarshalBinary(data[:len(data)-1]); err != ErrHistogramFormat {
+		t.Errorf("truncated data should fail with ErrHistogramFormat, not %v", err)
+	}
+
+	huge := append([]byte(histogramMagic), 1, 0x10, 0x20, 0x30)
+	huge = binary.AppendUvarint(huge, 1<<40)
+	if err := dense.UnmarshalBinary(huge); err != ErrHistogramFormat {
+		t.Errorf("a count over 32 bits should fail with ErrHistogramFormat, not %v", err)
+	}
+}
+
+func TestHistogramJSON(t *testing.T) {
+	s := NewCountedSet(10)
+	s.AddCount(RGB{0x9a, 0x44, 0xbc}, 12)
+	s.AddCount(two, 3)
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if str := string(data); str != `{"#0216de":3,"#9a44bc":12}` {
+		t.Errorf("JSON should be an object of hex codes, not %s", str)
+	}
+
+	var dense DenseSet
+	if err := json.Unmarshal(data, &dense); err != nil {
+		t.Fatal(err)
+	}
+	if dense.Count(RGB{0x9a, 0x44, 0xbc}) != 12 || dense.Len() != 2 {
+		t.Error("DenseSet decoded incorrect counts")
+	}
+
+	out, _ := json.Marshal(&dense)
+	var counted CountedSet
+	if err := json.Unmarshal(out, &counted); err != nil {
+		t.Fatal(err)
+	}
+	if counted.Count(RGB{0x98, 0x44, 0xbc}) != 12 {
+		t.Errorf("DenseSet should encode detuned colors, not %s", out)
+	}
+
+	for _, bad := range []string{`{"#9a44bc":-1}`, `{"#9a44bc":4294967296}`} {
+		if err := json.Unmarshal([]byte(bad), &dense); err != ErrHistogramFormat {
+			t.Errorf("%s should fail with ErrHistogramFormat, not %v", bad, err)
+		}
+	}
+}
+
+func TestResultJSON(t *testing.T) {
+	r := AnalyzeResult(testImage(60, 40), &Options{KeepHistograms: true})
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got Result
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Background.String() != r.Background.String() || got.Primary.String() != r.Primary.String() ||
+		got.Secondary.String() != r.Secondary.String() || got.Detail.String() != r.Detail.String() {
+		t.Errorf("%s decoded as %+v", data, got)
+	}
+	if got.Histogram != nil {
+		t.Error("histograms should not be encoded")
+	}
+
+	for i := 0; i < 256; i++ {
+		c := RGB{uint8(i), uint8(i), uint8(i)}
+		var d Color
+		if err := d.UnmarshalText([]byte(c.String())); err != nil || d.String() != c.String() {
+			t.Errorf("%s decoded as %s, %v", c, d, err)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Package server analyzes images over HTTP.  NewHandler returns an
+// http.Handler that can be mounted in any server:
+//
+//	POST /analyze  an image as the request body, or a multipart form with
+//	               any number of image files
+//	GET  /health   reports that the server is up
+//
+// Results are returned as JSON.
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	"github.com/disintegration/gift"
+	"github.com/sspencer/colorart"
+	"github.com/sspencer/colorart/loader"
+)
+
+const (
+	// DefaultMaxUploadSize is the largest request body accepted when
+	// Options.MaxUploadSize is 0.
+	DefaultMaxUploadSize = 32 << 20
+
+	// DefaultMaxPixels is the largest image decoded when Options.MaxPixels
+	// is 0.
+	DefaultMaxPixels = loader.DefaultMaxPixels
+)
+
+// Options configures a Handler.  The zero value (or a nil *Options) uses
+// the defaults.
+type Options struct {
+	// MaxUploadSize is the largest request body accepted, in bytes.
+	MaxUploadSize int64
+
+	// MaxPixels is the largest image, in width times height, that will be
+	// decoded.  The size is read from the image header before decoding.
+	MaxPixels int64
+
+	// Size shrinks images wider or higher than this many pixels before
+	// they are analyzed.  0 analyzes them as they are.
+	Size int
+
+	// Analyze is used to analyze each image.  Set Analyze.Pool to bound
+	// the goroutines used by all requests together.
+	Analyze *colorart.Options
+}
+
+// Response is the JSON result for one image.  File is the name of the
+// uploaded file for multipart forms.
+type Response struct {
+	File string `json:"file,omitempty"`
+	colorart.Result
+}
+
+// errorResponse is the JSON body of every error.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// httpError is an error with the status code it is returned with.
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e *httpError) Error() string { return e.msg }
+
+type handler struct {
+	opts Options
+}
+
+// NewHandler returns a handler serving /analyze and /health.
+func NewHandler(opts *Options) http.Handler {
+	h := &handler{}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.MaxUploadSize <= 0 {
+		h.opts.MaxUploadSize = DefaultMaxUploadSize
+	}
+	if h.opts.MaxPixels <= 0 {
+		h.opts.MaxPixels = DefaultMaxPixels
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/analyze", h.analyze)
+	mux.HandleFunc("/health", h.health)
+	return mux
+}
+
+func (h *handler) health(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, &httpError{http.StatusMethodNotAllowed, "method not allowed"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// analyze returns a Response for an image body, or a list of them for a
+// multipart form.
+func (h *handler) analyze(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeError(w, &httpError{http.StatusMethodNotAllowed, "method not allowed"})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.opts.MaxUploadSize)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, readError(err))
+			return
+		}
+
+		res, err := h.analyzeImage(data)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, &httpError{http.StatusBadRequest, err.Error()})
+		return
+	}
+
+	list := []Response{}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeError(w, readError(err))
+			return
+		}
+
+		if part.FileName() == "" {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(part)
+		if err != nil {
+			writeError(w, readError(err))
+			return
+		}
+
+		res, err := h.analyzeImage(data)
+		if err != nil {
+			writeError(w, fmt.Errorf("%s: %w", part.FileName(), err))
+			return
+		}
+
+		res.File = part.FileName()
+		list = append(list, res)
+	}
+
+	if len(list) == 0 {
+		writeError(w, &httpError{http.StatusBadRequest, "no files in form"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// analyzeImage decodes and analyzes an uploaded image with loader.Decode,
+// unless its header says it is larger than MaxPixels.
+func (h *handler) analyzeImage(data []byte) (Response, error) {
+	img, _, err := loader.Decode(bytes.NewReader(data), h.opts.MaxPixels)
+	if errors.Is(err, loader.ErrTooLarge) {
+		return Response{}, &httpError{http.StatusRequestEntityTooLarge, err.Error()}
+	}
+	if err == image.ErrFormat {
+		return Response{}, &httpError{http.StatusUnsupportedMediaType, "unsupported image format"}
+	}
+	if err != nil {
+		return Response{}, &httpError{http.StatusBadRequest, err.Error()}
+	}
+
+	if b := img.Bounds(); h.opts.Size > 0 && (b.Dx() > h.opts.Size || b.Dy() > h.opts.Size) {
+		w, ht := h.opts.Size, 0
+		if b.Dy() > b.Dx() {
+			w, ht = 0, h.opts.Size
+		}
+
+		g := gift.New(gift.Resize(w, ht, gift.LanczosResampling))
+		dst := image.NewRGBA(g.Bounds(b))
+		g.Draw(dst, img)
+		img = dst
+	}
+
+	return Response{Result: colorart.AnalyzeResult(img, h.opts.Analyze)}, nil
+}
+
+// readError turns an error reading the request into an httpError.
+func readError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return &httpError{http.StatusRequestEntityTooLarge, fmt.Sprintf("upload is larger than %d bytes", tooLarge.Limit)}
+	}
+	return &httpError{http.StatusBadRequest, err.Error()}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var he *httpError
+	if errors.As(err, &he) {
+		status = he.status
+	}
+
+	writeJSON(w, status, errorResponse{err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{200, 30, 30, 255}
+			if x > w/4 && x < 3*w/4 {
+				c = color.RGBA{20, 200, 40, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func post(h http.Handler, contentType string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/analyze", bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAnalyzeBody(t *testing.T) {
+	rec := post(NewHandler(nil), "image/png", encodePNG(t, 40, 30))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	var res Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Background.String() != "#c81c1c" {
+		t.Errorf("background should be #c81c1c, not %s", res.Background)
+	}
+}
+
+func TestAnalyzeMultipart(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("comment", "not a file")
+	for _, name := range []string{"a.png", "b.png"} {
+		fw, err := mw.CreateFormFile("image", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(encodePNG(t, 20, 20))
+	}
+	mw.Close()
+
+	rec := post(NewHandler(nil), mw.FormDataContentType(), body.Bytes())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	var list []Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].File != "a.png" || list[1].File != "b.png" {
+		t.Errorf("expected results for a.png and b.png, got %s", rec.Body)
+	}
+}
+
+func TestAnalyzeLimits(t *testing.T) {
+	data := encodePNG(t, 40, 30)
+
+	tests := []struct {
+		name string
+		opts *Options
+		body []byte
+		code int
+	}{
+		{"pixels", &Options{MaxPixels: 1000}, data, http.StatusRequestEntityTooLarge},
+		{"upload", &Options{MaxUploadSize: 10}, data, http.StatusRequestEntityTooLarge},
+		{"format", nil, []byte("not an image"), http.StatusUnsupportedMediaType},
+		{"truncated", nil, data[:40], http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		rec := post(NewHandler(tt.opts), "application/octet-stream", tt.body)
+		if rec.Code != tt.code {
+			t.Errorf("%s: status should be %d, not %d: %s", tt.name, tt.code, rec.Code, rec.Body)
+		}
+
+		var e errorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &e); err != nil || e.Error == "" {
+			t.Errorf("%s: expected a JSON error, got %s", tt.name, rec.Body)
+		}
+	}
+}
+
+func TestHealth(t *testing.T) {
+	h := NewHandler(nil)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("health status should be 200, not %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/analyze", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /analyze status should be 405, not %d", rec.Code)
+	}
+}
@@ -0,0 +1,94 @@
+package colorart
+
+// Smoother eases each color of successive results toward the newest one,
+// so colors taken from consecutive video frames or slides don't flicker.
+// Colors are compared and mixed in CIE L*a*b*, so equal steps look equal.
+// A Smoother is not thread safe.
+type Smoother struct {
+	// Alpha is how far each color moves toward the newest result, from 0
+	// (never moves) to 1 (no smoothing).
+	Alpha float64
+
+	// Hysteresis is the color difference (DeltaE) a new color must exceed
+	// before the smoothed color starts moving toward it.  0 always moves.
+	Hysteresis float64
+
+	// SceneThreshold is the background color difference (DeltaE) at which
+	// a new result starts a new scene: every color jumps straight to the
+	// new result and SceneChange reports true.  0 never starts a scene.
+	SceneThreshold float64
+
+	cur     Result
+	started bool
+	scene   bool
+}
+
+// NewSmoother creates a Smoother with the given Alpha.
+func NewSmoother(alpha float64) *Smoother {
+	return &Smoother{Alpha: alpha}
+}
+
+// Update adds the next result and returns the smoothed result.  The first
+// result is returned unchanged.  The fallback flags are those of the newest
+// result.
+func (s *Smoother) Update(r Result) Result {
+	next := Result{
+		Background:        r.Background,
+		Primary:           r.Primary,
+		Secondary:         r.Secondary,
+		Detail:            r.Detail,
+		PrimaryFallback:   r.PrimaryFallback,
+		SecondaryFallback: r.SecondaryFallback,
+		DetailFallback:    r.DetailFallback,
+	}
+
+	if !s.started {
+		s.cur = next
+		s.started = true
+		s.scene = true
+		return s.cur
+	}
+
+	s.scene = s.SceneThreshold > 0 && s.cur.Background.DeltaE(r.Background) > s.SceneThreshold
+	if s.scene {
+		s.cur = next
+		return s.cur
+	}
+
+	s.cur.Background = s.ease(s.cur.Background, r.Background)
+	s.cur.Primary = s.ease(s.cur.Primary, r.Primary)
+	s.cur.Secondary = s.ease(s.cur.Secondary, r.Secondary)
+	s.cur.Detail = s.ease(s.cur.Detail, r.Detail)
+	s.cur.PrimaryFallback = r.PrimaryFallback
+	s.cur.SecondaryFallback = r.SecondaryFallback
+	s.cur.DetailFallback = r.DetailFallback
+	return s.cur
+}
+
+// SceneChange reports whether the most recent Update started a new scene.
+// The first result always starts a scene.
+func (s *Smoother) SceneChange() bool {
+	return s.scene
+}
+
+// Reset forgets all previous results.
+func (s *Smoother) Reset() {
+	s.cur = Result{}
+	s.started = false
+	s.scene = false
+}
+
+// ease moves c Alpha of the way toward d, unless they are within Hysteresis.
+func (s *Smoother) ease(c, d Color) Color {
+	if s.Hysteresis > 0 && c.DeltaE(d) <= s.Hysteresis {
+		return c
+	}
+	return mixLab(c, d, s.Alpha)
+}
+
+// mixLab returns the color t of the way from c to d in CIE L*a*b*.
+func mixLab(c, d Color, t float64) Color {
+	l1, a1, b1 := c.Lab()
+	l2, a2, b2 := d.Lab()
+	return LabToColor(l1+(l2-l1)*t, a1+(a2-a1)*t, b1+(b2-b1)*t)
+}
@@ -0,0 +1,136 @@
+package colorart
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"io"
+)
+
+// RowSource supplies an image one row at a time, top to bottom, so images
+// too large to hold in memory can be analyzed.
+type RowSource interface {
+	// Width returns the number of pixels in each row.
+	Width() int
+	// Height returns the number of rows.
+	Height() int
+	// NextRow fills row, which has Width() pixels, with the next row.  It
+	// returns io.EOF once every row has been read.
+	NextRow(row []color.NRGBA) error
+}
+
+// ErrShortSource is returned by AnalyzeRows when a RowSource ends before
+// Height() rows were read.
+var ErrShortSource = errors.New("colorart: row source ended early")
+
+// pixelRows is a RowSource that can also read rows at the precision of
+// the image, as Analyze does.  ImageRows implements it.
+type pixelRows interface {
+	RowSource
+	nextPixels(row []pixel) error
+}
+
+// AnalyzeRows analyzes the rows of src as they are read, holding only one
+// row in memory.  Rows from ImageRows give the same result as Analyze of
+// the image; other sources give the same result as Analyze of an 8 bit
+// image of their pixels.  Only opts.KeepHistograms is used, as rows are
+// counted as they arrive.
+func AnalyzeRows(src RowSource, opts *Options) (Result, error) {
+	w, h := src.Width(), src.Height()
+	if w <= 0 || h <= 0 {
+		return Result{}, errors.New("colorart: empty row source")
+	}
+
+	pixels := make([]pixel, w)
+	var next func() error
+	if ps, ok := src.(pixelRows); ok {
+		next = func() error { return ps.nextPixels(pixels) }
+	} else {
+		row := make([]color.NRGBA, w)
+		next = func() error {
+			if err := src.NextRow(row); err != nil {
+				return err
+			}
+			for x, c := range row {
+				pixels[x] = nrgbaPixel(c)
+			}
+			return nil
+		}
+	}
+
+	edgeColors := NewCountedSet(500)
+	imageColors := NewHistogram(10000)
+
+	for y := 0; y < h; y++ {
+		if err := next(); err != nil {
+			if err == io.EOF {
+				err = ErrShortSource
+			}
+			return Result{}, err
+		}
+
+		edgeColors.AddPixel(pixels[0])
+		edgeColors.AddPixel(pixels[w-1])
+
+		if y%loopSkipper == 0 {
+			for x := 0; x < w; x += loopSkipper {
+				imageColors.AddPixel(pixels[x])
+			}
+		}
+	}
+
+	r := selectColors(edgeColors, imageColors)
+	if opts != nil && opts.KeepHistograms {
+		r.Histogram = imageColors
+		r.EdgeHistogram = edgeColors
+	}
+
+	return r, nil
+}
+
+func nrgbaPixel(c color.NRGBA) pixel {
+	return pixel{float32(c.R) * qf8, float32(c.G) * qf8, float32(c.B) * qf8, float32(c.A) * qf8}
+}
+
+type imageRows struct {
+	img    image.Image
+	pixels *pixelGetter
+	y      int
+}
+
+// ImageRows returns a RowSource that reads the rows of img.
+func ImageRows(img image.Image) RowSource {
+	return &imageRows{img: img, pixels: newPixelGetter(img)}
+}
+
+func (r *imageRows) Width() int  { return r.img.Bounds().Dx() }
+func (r *imageRows) Height() int { return r.img.Bounds().Dy() }
+
+func (r *imageRows) NextRow(row []color.NRGBA) error {
+	b := r.img.Bounds()
+	if r.y >= b.Dy() {
+		return io.EOF
+	}
+
+	y := b.Min.Y + r.y
+	for x := b.Min.X; x < b.Max.X; x++ {
+		row[x-b.Min.X] = color.NRGBAModel.Convert(r.img.At(x, y)).(color.NRGBA)
+	}
+	r.y++
+	return nil
+}
+
+// nextPixels reads the next row the way Analyze reads the image.
+func (r *imageRows) nextPixels(row []pixel) error {
+	b := r.img.Bounds()
+	if r.y >= b.Dy() {
+		return io.EOF
+	}
+
+	y := b.Min.Y + r.y
+	for x := b.Min.X; x < b.Max.X; x++ {
+		row[x-b.Min.X] = r.pixels.getPixel(x, y)
+	}
+	r.y++
+	return nil
+}
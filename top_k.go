@@ -0,0 +1,100 @@
+package colorart
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// entryBefore reports whether a is ordered before b: greatest count first,
+// then lowest color (red, then green, then blue) so that entries with equal
+// counts always come out in the same order.
+func entryBefore(a, b CountedEntry) bool {
+	if a.Count != b.Count {
+		return a.Count > b.Count
+	}
+	return colorBefore(a.Color, b.Color)
+}
+
+// colorBefore orders colors by red, then green, then blue.
+func colorBefore(a, b RGB) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// entryHeap is a heap whose root is the entry ordered first.
+type entryHeap []CountedEntry
+
+func (h entryHeap) Len() int            { return len(h) }
+func (h entryHeap) Less(i, j int) bool  { return entryBefore(h[i], h[j]) }
+func (h entryHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *entryHeap) Push(x interface{}) { *h = append(*h, x.(CountedEntry)) }
+func (h *entryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	*h = old[:n-1]
+	return e
+}
+
+// lastHeap is a heap whose root is the entry ordered last.
+type lastHeap struct{ entryHeap }
+
+func (h lastHeap) Less(i, j int) bool { return entryBefore(h.entryHeap[j], h.entryHeap[i]) }
+
+// EntryIterator returns the entries of a Histogram from greatest count to
+// least.  Entries are ordered lazily, so stopping after the first few is
+// much cheaper than SortedSet.
+type EntryIterator struct {
+	h entryHeap
+}
+
+// NewEntryIterator creates an iterator over the entries of s.  Later
+// changes to s are not seen by the iterator.
+func NewEntryIterator(s Histogram) *EntryIterator {
+	it := &EntryIterator{h: make(entryHeap, 0, s.Len())}
+	s.Range(func(color RGB, cnt int) {
+		it.h = append(it.h, CountedEntry{color, cnt})
+	})
+	heap.Init(&it.h)
+	return it
+}
+
+// Next returns the next entry, or false once every entry has been returned.
+func (it *EntryIterator) Next() (CountedEntry, bool) {
+	if len(it.h) == 0 {
+		return CountedEntry{}, false
+	}
+	return heap.Pop(&it.h).(CountedEntry), true
+}
+
+// Remaining returns the number of entries not yet returned by Next.
+func (it *EntryIterator) Remaining() int {
+	return len(it.h)
+}
+
+// TopK returns the k entries of s with the greatest count, ordered from
+// greatest count to least.
+func TopK(s Histogram, k int) []CountedEntry {
+	if k <= 0 {
+		return nil
+	}
+
+	h := lastHeap{make(entryHeap, 0, k)}
+	s.Range(func(color RGB, cnt int) {
+		e := CountedEntry{color, cnt}
+		if len(h.entryHeap) < k {
+			heap.Push(&h, e)
+		} else if entryBefore(e, h.entryHeap[0]) {
+			h.entryHeap[0] = e
+			heap.Fix(&h, 0)
+		}
+	})
+
+	list := []CountedEntry(h.entryHeap)
+	sort.Sort(ByCount(list))
+	return list
+}
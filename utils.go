@@ -2,58 +2,95 @@ package colorart
 
 import (
 	"runtime"
-	"sync/atomic"
+	"sync"
 )
 
-type countedFn func(ch chan CountedSet, pmin, pmax int)
+type countedFn func(ch chan Histogram, pmin, pmax int)
 
-// parallelize data processing if 'enabled' is true
-func parallelize(datamin, datamax int, fn countedFn) CountedSet {
+// Pool bounds the number of goroutines that concurrent Analyze calls may
+// run at once.  A single Pool can be shared by any number of callers.
+type Pool struct {
+	sem chan struct{}
+}
+
+// NewPool creates a Pool allowing at most size goroutines to count pixels
+// at the same time.  A size < 1 defaults to runtime.GOMAXPROCS(0).
+func NewPool(size int) *Pool {
+	if size < 1 {
+		size = runtime.GOMAXPROCS(0)
+	}
+	return &Pool{sem: make(chan struct{}, size)}
+}
+
+// Size returns the maximum number of goroutines the pool will run at once.
+func (p *Pool) Size() int {
+	return cap(p.sem)
+}
+
+func (p *Pool) acquire() { p.sem <- struct{}{} }
+func (p *Pool) release() { <-p.sem }
+
+// parallelize splits [datamin, datamax) into at most 'workers' parts and runs
+// fn over each part.  A nil pool runs each part in its own goroutine, and a
+// single part without a pool runs on the calling goroutine.
+func parallelize(datamin, datamax, workers int, pool *Pool, fn countedFn) Histogram {
 	datasize := datamax - datamin
-	partsize := datasize
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if pool != nil && workers > pool.Size() {
+		workers = pool.Size()
+	}
+	if workers > datasize {
+		workers = datasize
+	}
 
-	numGoroutines := 1
-	numProcs := runtime.GOMAXPROCS(0)
+	if workers < 1 {
+		return NewHistogram(0)
+	}
+
+	// if partsize had a fraction, bump it by 1 so entire image is covered
+	partsize := (datasize + workers - 1) / workers
+	numParts := (datasize + partsize - 1) / partsize
+
+	ch := make(chan Histogram, numParts)
+
+	if numParts == 1 && pool == nil {
+		fn(ch, datamin, datamax)
+		return <-ch
+	}
 
-	if numProcs > 1 {
-		numGoroutines = numProcs
-		partsize = partsize / numGoroutines
-		if partsize < 1 {
-			partsize = 1
+	var wg sync.WaitGroup
+	for pmin := datamin; pmin < datamax; pmin += partsize {
+		pmax := pmin + partsize
+		if pmax > datamax {
+			pmax = datamax
 		}
 
-		// if partsize had a fraction, bump it by 1 so entire image is covered
-		if partsize*numGoroutines < datasize {
-			partsize++
+		if pool != nil {
+			pool.acquire()
 		}
-	}
 
-	idx := int64(datamin)
-	ch := make(chan CountedSet, numGoroutines)
-
-	for p := 0; p < numGoroutines; p++ {
-		go func() {
-			for {
-				pmin := int(atomic.AddInt64(&idx, int64(partsize))) - partsize
-				if pmin >= datamax {
-					break
-				}
-				pmax := pmin + partsize
-				if pmax > datamax {
-					pmax = datamax
-				}
-				fn(ch, pmin, pmax)
+		wg.Add(1)
+		go func(pmin, pmax int) {
+			defer wg.Done()
+			if pool != nil {
+				defer pool.release()
 			}
-		}()
+			fn(ch, pmin, pmax)
+		}(pmin, pmax)
 	}
 
-	colors := NewCountedSet(10000)
+	wg.Wait()
+	close(ch)
 
-	for p := 0; p < numGoroutines; p++ {
-		colors.Merge(<-ch)
+	colors := <-ch
+	for c := range ch {
+		mergeHistogram(colors, c)
+		if d, ok := c.(*DenseSet); ok {
+			d.release()
+		}
 	}
 
-	close(ch)
-
 	return colors
 }
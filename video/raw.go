@@ -0,0 +1,66 @@
+package video
+
+import (
+	"image"
+	"io"
+
+	"github.com/sspencer/colorart/loader"
+)
+
+// RawReader reads a stream of packed 8 bit RGB frames (rgb24) with no
+// header, such as the output of "ffmpeg -f rawvideo -pix_fmt rgb24".
+// Frames are returned as *image.NRGBA.
+type RawReader struct {
+	r     io.Reader
+	fps   float64
+	buf   []byte
+	frame *image.NRGBA
+}
+
+// NewRawReader reads width x height rgb24 frames shown at fps frames per
+// second from r, within loader.DefaultMaxPixels.
+func NewRawReader(r io.Reader, width, height int, fps float64) (*RawReader, error) {
+	return NewRawReaderLimit(r, width, height, fps, loader.DefaultMaxPixels)
+}
+
+// NewRawReaderLimit reads frames like NewRawReader, but fails with an error
+// wrapping loader.ErrTooLarge, before allocating any frame, if they have
+// more than maxPixels pixels.
+func NewRawReaderLimit(r io.Reader, width, height int, fps float64, maxPixels int64) (*RawReader, error) {
+	if width <= 0 || height <= 0 || !validRate(fps) {
+		return nil, ErrFormat
+	}
+	if err := loader.CheckSize(width, height, 1, maxPixels); err != nil {
+		return nil, err
+	}
+
+	return &RawReader{
+		r:     r,
+		fps:   fps,
+		buf:   make([]byte, width*height*3),
+		frame: image.NewNRGBA(image.Rect(0, 0, width, height)),
+	}, nil
+}
+
+// FrameRate returns the number of frames per second.
+func (r *RawReader) FrameRate() float64 { return r.fps }
+
+// ReadFrame returns the next frame, or io.EOF after the last.
+func (r *RawReader) ReadFrame() (image.Image, error) {
+	if _, err := io.ReadFull(r.r, r.buf); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	pix := r.frame.Pix
+	for i, j := 0, 0; i < len(r.buf); i, j = i+3, j+4 {
+		pix[j+0] = r.buf[i+0]
+		pix[j+1] = r.buf[i+1]
+		pix[j+2] = r.buf[i+2]
+		pix[j+3] = 0xff
+	}
+
+	return r.frame, nil
+}
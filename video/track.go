@@ -0,0 +1,90 @@
+package video
+
+import (
+	"io"
+	"time"
+
+	"github.com/sspencer/colorart"
+)
+
+// defaultSmoothing is the Smoother alpha used when TrackOptions.Smoothing is 0.
+const defaultSmoothing = 0.25
+
+// Sample is the smoothed colors of a video at one point in time.
+type Sample struct {
+	Time   time.Duration
+	Frame  int
+	Result colorart.Result
+
+	// SceneChange is true when the background jumped by more than
+	// TrackOptions.SceneThreshold, and on the first sample.
+	SceneChange bool
+}
+
+// TrackOptions controls how a color track is extracted.
+type TrackOptions struct {
+	// Every analyzes only every Nth frame.  0 analyzes every frame.
+	Every int
+
+	// Smoothing is how far colors move toward those of each new sample,
+	// from just above 0 (very smooth) to 1 (no smoothing).  0 uses 0.25.
+	Smoothing float64
+
+	// Hysteresis and SceneThreshold are passed to the colorart.Smoother.
+	Hysteresis     float64
+	SceneThreshold float64
+
+	// Options is used to analyze each frame.
+	Options *colorart.Options
+}
+
+// StreamTrack analyzes the frames read from fr and calls fn with each
+// smoothed sample as soon as it is ready.  It stops at the end of the
+// stream or at the first error returned by fr or fn.
+func StreamTrack(fr FrameReader, opts *TrackOptions, fn func(Sample) error) error {
+	var o TrackOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Every < 1 {
+		o.Every = 1
+	}
+	if o.Smoothing <= 0 {
+		o.Smoothing = defaultSmoothing
+	}
+
+	smoother := colorart.NewSmoother(o.Smoothing)
+	smoother.Hysteresis = o.Hysteresis
+	smoother.SceneThreshold = o.SceneThreshold
+	fps := fr.FrameRate()
+
+	for n := 0; ; n++ {
+		img, err := fr.ReadFrame()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if n%o.Every != 0 {
+			continue
+		}
+
+		r := smoother.Update(colorart.AnalyzeResult(img, o.Options))
+		t := time.Duration(float64(n) / fps * float64(time.Second))
+		if err := fn(Sample{Time: t, Frame: n, Result: r, SceneChange: smoother.SceneChange()}); err != nil {
+			return err
+		}
+	}
+}
+
+// ExtractTrack analyzes the frames read from fr and returns every sample.
+func ExtractTrack(fr FrameReader, opts *TrackOptions) ([]Sample, error) {
+	var track []Sample
+	err := StreamTrack(fr, opts, func(s Sample) error {
+		track = append(track, s)
+		return nil
+	})
+	return track, err
+}
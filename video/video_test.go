@@ -0,0 +1,175 @@
+package video
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sspencer/colorart/loader"
+)
+
+func y4mStream(frames int, y, cb, cr byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("YUV4MPEG2 W4 H2 F10:1 Ip A1:1 C420jpeg\n")
+	for i := 0; i < frames; i++ {
+		buf.WriteString("FRAME\n")
+		buf.Write(bytes.Repeat([]byte{y}, 8))
+		buf.Write([]byte{cb, cb})
+		buf.Write([]byte{cr, cr})
+	}
+	return buf.Bytes()
+}
+
+func TestY4MReader(t *testing.T) {
+	r, err := NewY4MReader(bytes.NewReader(y4mStream(3, 81, 90, 240)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Width() != 4 || r.Height() != 2 || r.FrameRate() != 10 {
+		t.Errorf("header should be 4x2 at 10fps, not %dx%d at %g", r.Width(), r.Height(), r.FrameRate())
+	}
+
+	img, err := r.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := img.(*image.YCbCr); !ok {
+		t.Errorf("frame should be *image.YCbCr, not %T", img)
+	}
+}
+
+func TestExtractTrack(t *testing.T) {
+	stream := append(y4mStream(4, 81, 90, 240), y4mStream(4, 145, 54, 34)[len("YUV4MPEG2 W4 H2 F10:1 Ip A1:1 C420jpeg\n"):]...)
+	r, err := NewY4MReader(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	track, err := ExtractTrack(r, &TrackOptions{Every: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(track) != 4 {
+		t.Fatalf("got %d samples, want 4", len(track))
+	}
+	if track[1].Frame != 2 || track[1].Time != 200*time.Millisecond {
+		t.Errorf("second sample should be frame 2 at 200ms, not %d at %s", track[1].Frame, track[1].Time)
+	}
+
+	// smoothing moves gradually from the first scene to the second
+	first, mid, last := track[1].Result.Background, track[2].Result.Background, track[3].Result.Background
+	if mid == first || mid == last {
+		t.Errorf("background should ease between scenes: %s %s %s", first, mid, last)
+	}
+
+	r, _ = NewY4MReader(bytes.NewReader(stream))
+	track, err = ExtractTrack(r, &TrackOptions{Every: 2, SceneThreshold: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !track[0].SceneChange || track[1].SceneChange || !track[2].SceneChange {
+		t.Error("scene changes should be reported on the first sample and the cut")
+	}
+	if track[2].Result.Background == track[1].Result.Background {
+		t.Error("background should jump on a scene change")
+	}
+}
+
+func TestY4MColorspace(t *testing.T) {
+	for _, tt := range []struct {
+		colorspace string
+		ok         bool
+	}{
+		{"420", true},
+		{"420jpeg", true},
+		{"420paldv", true},
+		{"420mpeg2", true},
+		{"422", true},
+		{"444", true},
+		{"mono", true},
+		{"420p10", false},
+		{"420p12", false},
+		{"420p16", false},
+		{"444p10", false},
+		{"mono16", false},
+	} {
+		_, err := NewY4MReader(bytes.NewReader([]byte("YUV4MPEG2 W4 H2 F10:1 C" + tt.colorspace + "\n")))
+		if (err == nil) != tt.ok {
+			t.Errorf("C%s: got error %v", tt.colorspace, err)
+		}
+	}
+}
+
+func TestY4MHeader(t *testing.T) {
+	for _, rate := range []string{"NaN", "nan:1", "Inf", "1:Inf", "1e308:1e-308", "-25:1", "0:1", "25:0"} {
+		header := "YUV4MPEG2 W4 H2 F" + rate + " C420jpeg\n"
+		if _, err := NewY4MReader(strings.NewReader(header)); !errors.Is(err, ErrFormat) {
+			t.Errorf("F%s should be an invalid frame rate, got %v", rate, err)
+		}
+	}
+	if _, err := NewRawReader(strings.NewReader(""), 4, 2, math.NaN()); !errors.Is(err, ErrFormat) {
+		t.Errorf("a NaN raw frame rate should be invalid, got %v", err)
+	}
+
+	// headers without newlines aren't buffered without end
+	endless := "YUV4MPEG2 W4 H2 F25:1 X" + strings.Repeat("x", 2*maxHeaderLen)
+	if _, err := NewY4MReader(strings.NewReader(endless)); !errors.Is(err, ErrFormat) {
+		t.Errorf("a stream header longer than %d bytes should be invalid, got %v", maxHeaderLen, err)
+	}
+
+	y, err := NewY4MReader(strings.NewReader("YUV4MPEG2 W4 H2 F25:1\nFRAME X" + strings.Repeat("x", 2*maxHeaderLen)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := y.ReadFrame(); !errors.Is(err, ErrFormat) {
+		t.Errorf("a frame header longer than %d bytes should be invalid, got %v", maxHeaderLen, err)
+	}
+}
+
+func TestFrameSizeLimit(t *testing.T) {
+	header := []byte("YUV4MPEG2 W100000 H100000 F25:1 C420jpeg\n")
+	if _, err := NewY4MReader(bytes.NewReader(header)); !errors.Is(err, loader.ErrTooLarge) {
+		t.Errorf("huge y4m frames should be too large, got %v", err)
+	}
+	if _, err := NewY4MReaderLimit(bytes.NewReader(y4mStream(1, 81, 90, 240)), 7); !errors.Is(err, loader.ErrTooLarge) {
+		t.Errorf("4x2 y4m frames should be too large for 7 pixels, got %v", err)
+	}
+
+	if _, err := NewRawReader(bytes.NewReader(nil), 100000, 100000, 25); !errors.Is(err, loader.ErrTooLarge) {
+		t.Errorf("huge raw frames should be too large, got %v", err)
+	}
+	if _, err := NewRawReaderLimit(bytes.NewReader(nil), 4, 2, 25, 8); err != nil {
+		t.Errorf("4x2 raw frames should fit in 8 pixels, got %v", err)
+	}
+}
+
+func TestRawReader(t *testing.T) {
+	data := bytes.Repeat([]byte{10, 20, 30}, 2*2*3)
+	r, err := NewRawReader(bytes.NewReader(data), 2, 2, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	for {
+		img, err := r.ReadFrame()
+		if err != nil {
+			break
+		}
+		if c := img.(*image.NRGBA).NRGBAAt(1, 1); c.R != 10 || c.G != 20 || c.B != 30 || c.A != 255 {
+			t.Errorf("pixel should be 10,20,30, not %v", c)
+		}
+		n++
+	}
+
+	if n != 3 {
+		t.Errorf("got %d frames, want 3", n)
+	}
+}
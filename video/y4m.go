@@ -0,0 +1,184 @@
+// Package video extracts a time coded color track from a sequence of raw
+// video frames, such as a YUV4MPEG2 (.y4m) stream piped from a decoder.
+package video
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/sspencer/colorart/loader"
+)
+
+// ErrFormat is returned for malformed or unsupported frame streams.
+var ErrFormat = errors.New("video: invalid frame stream")
+
+// maxHeaderLen is the longest stream or frame header read, so a stream
+// without newlines isn't buffered without end.
+const maxHeaderLen = 4096
+
+// FrameReader reads successive frames of a video.
+type FrameReader interface {
+	// ReadFrame returns the next frame, or io.EOF after the last.  The
+	// image is only valid until the next call.
+	ReadFrame() (image.Image, error)
+	// FrameRate returns the number of frames per second.
+	FrameRate() float64
+}
+
+// Y4MReader reads a YUV4MPEG2 stream.  Frames are returned as
+// *image.YCbCr, or *image.Gray for monochrome streams.
+type Y4MReader struct {
+	r      *bufio.Reader
+	width  int
+	height int
+	fps    float64
+	frame  image.Image
+	planes [][]byte
+}
+
+// NewY4MReader reads the stream header from r.  Frames may have up to
+// loader.DefaultMaxPixels pixels.
+func NewY4MReader(r io.Reader) (*Y4MReader, error) {
+	return NewY4MReaderLimit(r, loader.DefaultMaxPixels)
+}
+
+// NewY4MReaderLimit reads the stream header from r like NewY4MReader, but
+// fails with an error wrapping loader.ErrTooLarge, before allocating any
+// frame, if the frames have more than maxPixels pixels.
+func NewY4MReaderLimit(r io.Reader, maxPixels int64) (*Y4MReader, error) {
+	y := &Y4MReader{r: bufio.NewReaderSize(r, maxHeaderLen), fps: 25}
+
+	header, err := y.readHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Fields(header)
+	if len(fields) == 0 || fields[0] != "YUV4MPEG2" {
+		return nil, ErrFormat
+	}
+
+	colorspace := "420jpeg"
+	for _, f := range fields[1:] {
+		val := f[1:]
+		switch f[0] {
+		case 'W':
+			y.width, err = strconv.Atoi(val)
+		case 'H':
+			y.height, err = strconv.Atoi(val)
+		case 'F':
+			y.fps, err = parseRatio(val)
+		case 'C':
+			colorspace = val
+		}
+		if err != nil {
+			return nil, ErrFormat
+		}
+	}
+
+	if y.width <= 0 || y.height <= 0 || !validRate(y.fps) {
+		return nil, ErrFormat
+	}
+	if err := loader.CheckSize(y.width, y.height, 1, maxPixels); err != nil {
+		return nil, err
+	}
+
+	rect := image.Rect(0, 0, y.width, y.height)
+	var ratio image.YCbCrSubsampleRatio
+	// only 8 bit samples; 420p10 and the like have 16 bit samples
+	switch colorspace {
+	case "mono":
+		g := image.NewGray(rect)
+		y.frame = g
+		y.planes = [][]byte{g.Pix}
+		return y, nil
+	case "420", "420jpeg", "420paldv", "420mpeg2":
+		ratio = image.YCbCrSubsampleRatio420
+	case "422":
+		ratio = image.YCbCrSubsampleRatio422
+	case "444":
+		ratio = image.YCbCrSubsampleRatio444
+	default:
+		return nil, fmt.Errorf("video: unsupported y4m colorspace %q", colorspace)
+	}
+
+	img := image.NewYCbCr(rect, ratio)
+	y.frame = img
+	y.planes = [][]byte{img.Y, img.Cb, img.Cr}
+	return y, nil
+}
+
+func parseRatio(s string) (float64, error) {
+	num, den := s, "1"
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		num, den = s[:i], s[i+1:]
+	}
+
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, err
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0, ErrFormat
+	}
+	if r := n / d; validRate(r) {
+		return r, nil
+	}
+	return 0, ErrFormat
+}
+
+// validRate reports whether fps is a positive, finite frame rate.
+func validRate(fps float64) bool {
+	return !math.IsNaN(fps) && !math.IsInf(fps, 0) && fps > 0
+}
+
+// readHeader reads a header line of at most maxHeaderLen bytes.  A longer
+// line fails with ErrFormat.
+func (y *Y4MReader) readHeader() (string, error) {
+	line, err := y.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return "", fmt.Errorf("%w: header longer than %d bytes", ErrFormat, maxHeaderLen)
+	}
+	return string(line), err
+}
+
+// Width returns the width of each frame.
+func (y *Y4MReader) Width() int { return y.width }
+
+// Height returns the height of each frame.
+func (y *Y4MReader) Height() int { return y.height }
+
+// FrameRate returns the number of frames per second.
+func (y *Y4MReader) FrameRate() float64 { return y.fps }
+
+// ReadFrame returns the next frame, or io.EOF after the last.
+func (y *Y4MReader) ReadFrame() (image.Image, error) {
+	header, err := y.readHeader()
+	if err == io.EOF && header == "" {
+		return nil, io.EOF
+	}
+	if errors.Is(err, ErrFormat) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if !strings.HasPrefix(header, "FRAME") {
+		return nil, ErrFormat
+	}
+
+	for _, p := range y.planes {
+		if _, err := io.ReadFull(y.r, p); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+	}
+
+	return y.frame, nil
+}